@@ -0,0 +1,72 @@
+package go_kwin6
+
+import (
+	"context"
+	"fmt"
+)
+
+// Activity is a struct that contains the main properties of a Plasma Activity. Activities are distinct from virtual
+// desktops, every activity has its own set of virtual desktops and windows can be on one, several or all activities
+type Activity struct {
+	Id   string `json:"id"`
+	Name string `json:"name"`
+}
+
+// callActivityManager is a helper function which calls a method of the Plasma activity manager over dbus and returns
+// the string values found in the reply
+func (k KWin) callActivityManager(method string, args ...string) ([]string, error) {
+	output, err := k.callDbusSend(context.Background(), append([]string{
+		"--print-reply",
+		"--dest=org.kde.ActivityManager",
+		"/ActivityManager/Activities", "org.kde.ActivityManager.Activities." + method}, args...)...)
+	if err != nil {
+		return nil, err
+	}
+	values := make([]string, 0)
+	for _, s := range output {
+		if v, ok := dbusStringValue(s); ok {
+			values = append(values, v)
+		}
+	}
+	return values, nil
+}
+
+// GetActivities returns a map of the available Plasma Activity objects where the map key is the Activity ID. The
+// activities are read from the org.kde.ActivityManager dbus service, as KWin scripting only exposes their ids
+func (k KWin) GetActivities() (map[string]Activity, error) {
+	ids, err := k.callActivityManager("ListActivities")
+	if err != nil {
+		fmt.Printf("Error listing activities: %v\n", err)
+		return nil, err
+	}
+	outputMap := make(map[string]Activity)
+	for _, id := range ids {
+		names, err := k.callActivityManager("ActivityName", "string:"+id)
+		if err != nil {
+			fmt.Printf("Error getting activity name: %v\n", err)
+			return nil, err
+		}
+		a := Activity{Id: id}
+		if len(names) > 0 {
+			a.Name = names[0]
+		}
+		outputMap[id] = a
+	}
+	return outputMap, nil
+}
+
+// MoveWindowToActivity will attempt to move a given Window to a given Activity, taking it off every other activity.
+// A Window with an empty Activities list is on all activities
+func (k KWin) MoveWindowToActivity(w Window, activityId string) error {
+	script := `
+    windowId = "%s";
+    activityId = "%s";
+    for (const window of workspace.windowList()) {
+        wid = window.internalId.toString().replace(/{/, "").replace(/}/, "");
+        if (wid === windowId) {
+            window.activities = [activityId];
+            break;
+        }
+    }`
+	return k.runMutation(context.Background(), fmt.Sprintf(script, w.Id, escapeJSString(activityId)))
+}
@@ -0,0 +1,217 @@
+package go_kwin6
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// Batch operations act on many windows in a single script run. Instead of failing as a whole, they return the list of
+// windows which could not be acted on (e.g. the window is not moveable, or it was not found because it was closed in the
+// meantime), together with an error which is only set when the script itself could not be executed or its outcome is
+// unknown (the script threw, or its output was not captured completely)
+
+// batchEndMarker is printed by the batch scripts after the status of the last window, so a batch whose output was cut
+// short can be told apart from one where everything was applied
+const batchEndMarker = "#batch-end"
+
+// runWindowBatch executes the given JavaScript action function once for every given Window, in a single script. The
+// action receives the KWin::Window and returns true when it was applied. The script prints an "ok:<id>" or "fail:<id>"
+// status line for every window, followed by batchEndMarker. The windows for which the action returned false, or which
+// were not found, are returned. A script error, missing end marker or a window without a status is returned as an
+// error, as the outcome of the batch is unknown then
+func (k KWin) runWindowBatch(ws []Window, action string) ([]Window, error) {
+	script := `
+    windowIds = %s;
+    var act = %s;
+    var found = {};
+    for (const window of workspace.windowList()) {
+        var wid = window.internalId.toString().replace(/{/, "").replace(/}/, "");
+        if (!windowIds.includes(wid)) {
+            continue;
+        }
+        found[wid] = true;
+        print((act(window) ? "ok:" : "fail:")+wid);
+    }
+    for (const wid of windowIds) {
+        if (!found[wid]) {
+            print("fail:"+wid);
+        }
+    }
+    print("%s");`
+	ids := make([]string, len(ws))
+	for i, w := range ws {
+		ids[i] = w.Id
+	}
+	windowIds, err := json.Marshal(ids)
+	if err != nil {
+		return nil, err
+	}
+	output, err := k.loadExecuteAndGetOutput(fmt.Sprintf(script, windowIds, action, batchEndMarker))
+	if err != nil {
+		fmt.Printf("Error running batch script: %v\n", err)
+		return nil, err
+	}
+	applied, _, err := parseBatchOutput(output)
+	if err != nil {
+		return nil, err
+	}
+	failed := make([]Window, 0)
+	for _, w := range ws {
+		ok, reported := applied[w.Id]
+		if !reported {
+			return nil, fmt.Errorf("no batch status for window %s", w.Id)
+		}
+		if !ok {
+			failed = append(failed, w)
+		}
+	}
+	return failed, nil
+}
+
+// parseBatchOutput reads the output of a batch script: the "ok:<id>" and "fail:<id>" status lines, which are returned
+// as a map from the window id to whether the action was applied, and the JSON object lines, which are returned as they
+// are. A script error or a missing batchEndMarker is returned as an error
+func parseBatchOutput(output []string) (map[string]bool, []string, error) {
+	applied := make(map[string]bool)
+	objects := make([]string, 0)
+	other := make([]string, 0)
+	ended := false
+	for _, s := range output {
+		line := scriptLine(s)
+		switch {
+		case line == batchEndMarker:
+			ended = true
+		case strings.HasPrefix(line, "ok:"):
+			applied[strings.TrimPrefix(line, "ok:")] = true
+		case strings.HasPrefix(line, "fail:"):
+			applied[strings.TrimPrefix(line, "fail:")] = false
+		case strings.HasPrefix(line, "{"):
+			objects = append(objects, line)
+		default:
+			other = append(other, s)
+		}
+	}
+	if err := scriptError(other); err != nil {
+		return nil, nil, err
+	}
+	if !ended {
+		return nil, nil, fmt.Errorf("batch end marker not found: %w", ErrNoScriptOutput)
+	}
+	return applied, objects, nil
+}
+
+// MoveWindowsToScreen will attempt to move all given windows to a given Screen output in a single script run. It
+// returns the windows which could not be moved, because they are not moveable or were not found
+func (k KWin) MoveWindowsToScreen(ws []Window, s Screen) ([]Window, error) {
+	action := `function(window) {
+        if (!window.moveable) {
+            return false;
+        }
+        for (const screen of workspace.screens) {
+            if (screen.name === "%s") {
+                workspace.sendClientToScreen(window, screen);
+                return true;
+            }
+        }
+        return false;
+    }`
+	return k.runWindowBatch(ws, fmt.Sprintf(action, escapeJSString(s.Name)))
+}
+
+// MoveAllWindowsFromScreen will attempt to move all windows, which are currently on the given Screen output, to another
+// Screen output in a single script run. It returns the windows which could not be moved, because they are not moveable,
+// and an error when either Screen is not found. The returned windows are not enriched with the process information
+func (k KWin) MoveAllWindowsFromScreen(from, to Screen) ([]Window, error) {
+	script := windowToJSON + `
+    sourceScreenName = "%s";
+    targetScreenName = "%s";
+
+    var source = undefined;
+    var target = undefined;
+    for (const screen of workspace.screens) {
+        if (screen.name === sourceScreenName) {
+            source = screen;
+        }
+        if (screen.name === targetScreenName) {
+            target = screen;
+        }
+    }
+    if (!source || !target) {
+        print("%s");
+    } else {
+        for (const window of workspace.windowList()) {
+            if (window.specialWindow || !window.output || window.output.name !== sourceScreenName) {
+                continue;
+            }
+            var wid = window.internalId.toString().replace(/{/, "").replace(/}/, "");
+            if (window.moveable) {
+                workspace.sendClientToScreen(window, target);
+                print("ok:"+wid);
+            } else {
+                print(windowToJSON(window));
+                print("fail:"+wid);
+            }
+        }
+    }
+    print("%s");`
+	output, err := k.loadExecuteAndGetOutput(fmt.Sprintf(script, escapeJSString(from.Name), escapeJSString(to.Name),
+		notFoundMarker, batchEndMarker))
+	if err != nil {
+		fmt.Printf("Error running script for moving windows between screens: %v\n", err)
+		return nil, err
+	}
+	if hasMarker(output, notFoundMarker) {
+		return nil, fmt.Errorf("screen %s or %s not found", from.Name, to.Name)
+	}
+	applied, objects, err := parseBatchOutput(output)
+	if err != nil {
+		return nil, err
+	}
+	windows := make(map[string]Window, len(objects))
+	for _, s := range objects {
+		w, err := parseWindowLine(s)
+		if err != nil {
+			return nil, err
+		}
+		windows[w.Id] = w
+	}
+	failed := make([]Window, 0)
+	for id, ok := range applied {
+		if ok {
+			continue
+		}
+		w, found := windows[id]
+		if !found {
+			return nil, fmt.Errorf("no window reported for the failed window %s", id)
+		}
+		failed = append(failed, w)
+	}
+	sortWindows(failed)
+	return failed, nil
+}
+
+// MoveWindowsToDesktop will attempt to move all given windows to a given Desktop in a single script run, keeping their
+// geometry, so their arrangement relative to each other is preserved. It returns the windows which could not be moved,
+// because they are not moveable or were not found
+func (k KWin) MoveWindowsToDesktop(ws []Window, d Desktop) ([]Window, error) {
+	action := `function(window) {
+        if (!window.moveable) {
+            return false;
+        }
+        for (const desktop of workspace.desktops) {
+            if (desktop.id === "%s") {
+                var g = window.frameGeometry;
+                var geometry = {x: g.x, y: g.y, width: g.width, height: g.height};
+                window.desktops = [desktop];
+                g = window.frameGeometry;
+                if (g.x !== geometry.x || g.y !== geometry.y || g.width !== geometry.width || g.height !== geometry.height) {
+                    window.frameGeometry = geometry;
+                }
+                return true;
+            }
+        }
+        return false;
+    }`
+	return k.runWindowBatch(ws, fmt.Sprintf(action, d.Id))
+}
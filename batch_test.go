@@ -0,0 +1,47 @@
+package go_kwin6
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestParseBatchOutput(t *testing.T) {
+	tests := []struct {
+		name        string
+		output      []string
+		wantApplied map[string]bool
+		wantObjects []string
+		wantErr     error
+	}{
+		{
+			name:        "statuses",
+			output:      []string{"js: ok:a", "js: fail:b", `js: {"id": "b"}`, "js: " + batchEndMarker},
+			wantApplied: map[string]bool{"a": true, "b": false},
+			wantObjects: []string{`{"id": "b"}`},
+		},
+		{
+			name:        "no windows",
+			output:      []string{"js: " + batchEndMarker},
+			wantApplied: map[string]bool{},
+			wantObjects: []string{},
+		},
+		{name: "truncated", output: []string{"js: ok:a"}, wantErr: ErrNoScriptOutput},
+		{name: "no output", output: nil, wantErr: ErrNoScriptOutput},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			applied, objects, err := parseBatchOutput(tt.output)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("parseBatchOutput() error = %v, want %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(applied, tt.wantApplied) || !reflect.DeepEqual(objects, tt.wantObjects) {
+				t.Errorf("parseBatchOutput() = %v, %v, want %v, %v", applied, objects, tt.wantApplied, tt.wantObjects)
+			}
+		})
+	}
+	output := []string{"js: ok:a", "js: TypeError: act is not a function", batchEndMarker}
+	if _, _, err := parseBatchOutput(output); err == nil {
+		t.Errorf("parseBatchOutput() error = nil, want the script error")
+	}
+}
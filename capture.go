@@ -0,0 +1,321 @@
+package go_kwin6
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net/url"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// OutputJournal captures the script output from the system journal, using journalctl. This requires
+	// QT_LOGGING_RULES="kwin_*.debug=true" to be set for the Plasma session and read access to the journal
+	OutputJournal OutputMode = iota
+	// OutputDBus captures the script output without the journal. The print function of the script is replaced by one
+	// which sends every line, URI encoded, as a dbus method call to a unique object path of org.kde.KWin, while
+	// dbus-monitor records these calls into a file next to the script file, which is then read back and decoded. KWin
+	// doesn't implement that object path and answers with an error, which the script ignores, the call is only there
+	// for dbus-monitor to see. It does not depend on the logging rules or on the journal, but dbus-monitor must be
+	// allowed to monitor the session bus, which dbus-daemon and dbus-broker allow for the processes of the user owning
+	// the bus (with the BecomeMonitor method, or eavesdropping match rules on old dbus-daemon versions)
+	OutputDBus
+)
+
+const (
+	// PrintBuiltin prints the script output with the print function of KWin scripting
+	PrintBuiltin PrintFunction = iota
+	// PrintConsoleLog prints the script output with console.log, for the KWin versions or configurations where the
+	// output of print doesn't reach the journal
+	PrintConsoleLog
+)
+
+// PrintFunction selects the JavaScript function the scripts print their output with. The scripts always call print,
+// which is replaced, at the top of the script file, by a function calling the selected one. KWin scripts can't write
+// files, for a capture which doesn't depend on the journal at all use OutputDBus, which replaces print with a dbus call
+type PrintFunction int
+
+// shim returns the JavaScript snippet, prepended to every script file, which replaces the print function with the
+// selected one, or nothing for PrintBuiltin
+func (p PrintFunction) shim() string {
+	switch p {
+	case PrintConsoleLog:
+		return `
+	print = function() {
+		console.log(Array.prototype.slice.call(arguments).join(" "));
+	};
+	`
+	default:
+		return ""
+	}
+}
+
+// DetectPrintFunction returns the first PrintFunction whose output can be captured, trying PrintBuiltin first and then
+// PrintConsoleLog, by running Validate with each of them. Set the result as the PrintFunction of the KWin object. It
+// returns the error of the last attempt when none works
+func (k KWin) DetectPrintFunction() (PrintFunction, error) {
+	var err error
+	for _, p := range []PrintFunction{PrintBuiltin, PrintConsoleLog} {
+		k.PrintFunction = p
+		if err = k.Validate(); err == nil {
+			return p, nil
+		}
+	}
+	return PrintBuiltin, err
+}
+
+// dbusPrintShim is a JavaScript snippet, prepended to the scripts when OutputMode is OutputDBus, which replaces the print
+// function with one that sends each printed line as a dbus method call to the given object path. dbus-monitor prints
+// string arguments verbatim, without escaping quotes, backslashes or line breaks, so the line is URI encoded to keep
+// it on a single, unambiguous line of the capture, see dbusPrintedLine. Lone surrogates, which can't be encoded, are
+// replaced with the Unicode replacement character
+const dbusPrintShim = `
+	print = function() {
+		var line = Array.prototype.slice.call(arguments).join(" ");
+		try {
+			line = encodeURIComponent(line);
+		} catch (e) {
+			line = encodeURIComponent(line.replace(/[\uD800-\uDFFF]/g, "\uFFFD"));
+		}
+		callDBus("org.kde.KWin", "%s", "org.kde.GoKWin6", "print", line);
+	};
+	`
+
+// dbusOutputTimeout is the time to wait for the script output to show up in the dbus-monitor capture file
+const dbusOutputTimeout = 2 * time.Second
+
+// defaultJournalDeadline is the default maximum time to wait for the end marker when following the journal
+const defaultJournalDeadline = 5 * time.Second
+
+// OutputMode defines how the output printed by the KWin scripts is gathered
+type OutputMode int
+
+// loadExecuteAndGetDBusOutput executes given JavaScript code the same way as loadExecuteAndGetOutput, but captures the
+// printed output through dbus-monitor instead of journalctl:
+//
+//	Starting dbus-monitor, recording method calls to a unique object path into a file next to the script file
+//	Saving the script, prefixed by a print function which sends each line to that object path, into a temporary file
+//	Loading/Registering it with KWin scripting infrastructure
+//	Running the script
+//	Stopping the script
+//	Reading the capture file until the end marker, printed as the last line of the script, shows up
+func (k KWin) loadExecuteAndGetDBusOutput(ctx context.Context, script string) ([]string, error) {
+	token := strings.ReplaceAll(uuid.NewString(), "-", "_")
+	path := "/GoKWin6/Output_" + token
+	endMarker := "#end-" + token
+	scriptFile, err := k.createScriptFile(fmt.Sprintf(dbusPrintShim, path) + script + fmt.Sprintf("\n\tprint(\"%s\");\n", endMarker))
+	if err != nil {
+		return nil, err
+	}
+	defer k.removeScriptFile(scriptFile)
+
+	outputFile, err := os.Create(scriptFile.Name() + ".out")
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = outputFile.Close()
+		if err := os.Remove(outputFile.Name()); err != nil {
+			fmt.Printf("Error removing output file: %v\n", err)
+		}
+	}()
+
+	cmd := exec.CommandContext(ctx, dbusMonitor, "--session", fmt.Sprintf("type='method_call',path='%s'", path))
+	cmd.Stdout = outputFile
+	if err := cmd.Start(); err != nil {
+		fmt.Printf("Error starting dbus-monitor: %v\n", err)
+		return nil, err
+	}
+	defer func() {
+		_ = cmd.Process.Kill()
+		_ = cmd.Wait()
+	}()
+	// dbus-monitor reports its own name acquisition first, wait for it, so no script output is missed
+	if _, err := waitForFileContent(outputFile.Name(), "", dbusOutputTimeout); err != nil {
+		fmt.Printf("Error waiting for dbus-monitor: %v\n", err)
+		return nil, err
+	}
+
+	scriptNo, err := k.loadScript(ctx, scriptFile.Name())
+	if err != nil {
+		fmt.Printf("Error loading script: %v\n", err)
+		return nil, err
+	}
+	err = k.runScript(ctx, scriptNo)
+	if err != nil {
+		fmt.Printf("Error running script: %v\n", err)
+		_ = k.stopScript(context.Background(), scriptNo)
+		return nil, err
+	}
+	err = k.stopScript(context.Background(), scriptNo)
+	if err != nil {
+		fmt.Printf("Error stopping script: %v\n", err)
+		return nil, err
+	}
+
+	// The end marker is URI encoded in the capture, its "#" included, so only the rest of it is looked for
+	content, err := waitForFileContent(outputFile.Name(), strings.TrimPrefix(endMarker, "#"), dbusOutputTimeout)
+	if err != nil {
+		fmt.Printf("Error getting dbus output: %v\n", err)
+		return nil, err
+	}
+	output := make([]string, 0)
+	for _, s := range parseDBusMonitorStrings(content) {
+		if s == endMarker {
+			break
+		}
+		output = append(output, s)
+	}
+	return output, nil
+}
+
+// waitForFileContent polls the given file until it contains the given substring (or anything at all, when the
+// substring is empty) and returns its content. It returns an error when the timeout elapses first
+func waitForFileContent(name, substring string, timeout time.Duration) (string, error) {
+	deadline := time.Now().Add(timeout)
+	for {
+		content, err := os.ReadFile(name)
+		if err != nil {
+			return "", err
+		}
+		if len(content) > 0 && strings.Contains(string(content), substring) {
+			return string(content), nil
+		}
+		if time.Now().After(deadline) {
+			return "", fmt.Errorf("timed out waiting for output in %s", name)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// parseDBusMonitorStrings extracts the lines printed by a script through dbusPrintShim from the dbus-monitor output. Only
+// the arguments of the print method calls are read, the signals dbus-monitor reports about itself are skipped
+func parseDBusMonitorStrings(content string) []string {
+	output := make([]string, 0)
+	printCall := false
+	scanner := bufio.NewScanner(strings.NewReader(content))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if !strings.HasPrefix(line, " ") {
+			printCall = strings.HasPrefix(line, "method call ") && strings.HasSuffix(line, "member=print")
+			continue
+		}
+		if s, ok := dbusPrintedLine(line); ok && printCall {
+			output = append(output, s)
+		}
+	}
+	return output
+}
+
+// dbusPrintedLine decodes a line printed by a script through dbusPrintShim from a single line of dbus-monitor output.
+// It returns false for the lines which are not a string argument or not URI encoded (e.g. the strings of the messages
+// dbus-monitor reports about itself)
+func dbusPrintedLine(line string) (string, bool) {
+	s, ok := dbusStringValue(line)
+	if !ok {
+		return "", false
+	}
+	decoded, err := url.PathUnescape(s)
+	if err != nil {
+		return "", false
+	}
+	return decoded, true
+}
+
+// dbusStringValue extracts the value of a string argument from a single line of dbus-monitor or dbus-send --print-reply
+// output, both of which print them as indented `string "value"` lines following the message header line
+func dbusStringValue(line string) (string, bool) {
+	s := strings.TrimSpace(line)
+	if !strings.HasPrefix(s, "string \"") || !strings.HasSuffix(s, "\"") || len(s) < len("string \"\"") {
+		return "", false
+	}
+	return s[len("string \"") : len(s)-1], true
+}
+
+// loadExecuteAndFollowJournal executes given JavaScript code the same way as loadExecuteAndGetOutput, but instead of
+// querying the journal for the time window the script was running in, it follows the journal:
+//
+//	Saving the script, wrapped between a start and an end marker print, into a temporary file
+//	Loading/Registering it with KWin scripting infrastructure
+//	Starting journalctl --follow
+//	Running the script
+//	Stopping the script
+//	Reading the journal until the end marker shows up or the deadline passes, keeping the lines between the markers
+func (k KWin) loadExecuteAndFollowJournal(ctx context.Context, script string) ([]string, error) {
+	token := uuid.NewString()
+	startMarker := "#start-" + token
+	endMarker := "#end-" + token
+	scriptFile, err := k.createScriptFile(fmt.Sprintf("\n\tprint(\"%s\");\n", startMarker) + script +
+		fmt.Sprintf("\n\tprint(\"%s\");\n", endMarker))
+	if err != nil {
+		return nil, err
+	}
+	defer k.removeScriptFile(scriptFile)
+
+	scriptNo, err := k.loadScript(ctx, scriptFile.Name())
+	if err != nil {
+		fmt.Printf("Error loading script: %v\n", err)
+		return nil, err
+	}
+
+	deadline := k.JournalDeadline
+	if deadline <= 0 {
+		deadline = defaultJournalDeadline
+	}
+	cmd := exec.CommandContext(ctx, journalCtl, k.journalArgs(
+		"--since", k.now().Format(journalTimeFormat),
+		"--follow",
+		"--no-pager")...)
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		_ = k.stopScript(context.Background(), scriptNo)
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		_ = k.stopScript(context.Background(), scriptNo)
+		return nil, err
+	}
+	timer := time.AfterFunc(deadline, func() {
+		_ = cmd.Process.Kill()
+	})
+	defer func() {
+		timer.Stop()
+		_ = cmd.Process.Kill()
+		_ = cmd.Wait()
+	}()
+
+	err = k.runScript(ctx, scriptNo)
+	if err != nil {
+		fmt.Printf("Error running script: %v\n", err)
+		_ = k.stopScript(context.Background(), scriptNo)
+		return nil, err
+	}
+	err = k.stopScript(context.Background(), scriptNo)
+	if err != nil {
+		fmt.Printf("Error stopping script: %v\n", err)
+		return nil, err
+	}
+
+	output := make([]string, 0)
+	started := false
+	scanner := bufio.NewScanner(stdout)
+	for scanner.Scan() {
+		s := scanner.Text()
+		switch scriptLine(s) {
+		case startMarker:
+			started = true
+		case endMarker:
+			return output, nil
+		default:
+			if started {
+				output = append(output, s)
+			}
+		}
+	}
+	return nil, fmt.Errorf("script end marker not found in the journal within %v", deadline)
+}
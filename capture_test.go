@@ -0,0 +1,48 @@
+package go_kwin6
+
+import (
+	"reflect"
+	"testing"
+)
+
+// dbusMonitorCapture is the dbus-monitor output of a script printing, through dbusPrintShim, a window with quotes, a
+// backslash and a line break in its caption, followed by the end marker
+const dbusMonitorCapture = `signal time=1792252586.353645 sender=org.freedesktop.DBus -> destination=:1.0 serial=2 path=/org/freedesktop/DBus; interface=org.freedesktop.DBus; member=NameAcquired
+   string ":1.0"
+method call time=1792252587.055872 sender=:1.1 -> destination=org.kde.KWin serial=2 path=/GoKWin6/Output_1; interface=org.kde.GoKWin6; member=print
+   string "%7B%22caption%22%3A%20%22a%20%5C%22q%5C%22%20back%5C%5Cslash%20%231%2050%25%20%2Bx%5Cnline2%22%7D"
+error time=1792252587.055901 sender=:1.0 -> destination=:1.1 error_name=org.freedesktop.DBus.Error.UnknownObject reply_serial=2
+   string "No such object path '/GoKWin6/Output_1'"
+method call time=1792252587.056012 sender=:1.1 -> destination=org.kde.KWin serial=3 path=/GoKWin6/Output_1; interface=org.kde.GoKWin6; member=print
+   string "%23end-1"
+`
+
+func TestParseDBusMonitorStrings(t *testing.T) {
+	want := []string{`{"caption": "a \"q\" back\\slash #1 50% +x\nline2"}`, "#end-1"}
+	if got := parseDBusMonitorStrings(dbusMonitorCapture); !reflect.DeepEqual(got, want) {
+		t.Errorf("parseDBusMonitorStrings() = %q, want %q", got, want)
+	}
+}
+
+func TestDBusPrintedLine(t *testing.T) {
+	tests := []struct {
+		name   string
+		in     string
+		want   string
+		wantOk bool
+	}{
+		{"encoded", `   string "%7B%22id%22%3A%201%7D"`, `{"id": 1}`, true},
+		{"unreserved characters", `   string "a-b_c.d!e~f*g'h(i)"`, "a-b_c.d!e~f*g'h(i)", true},
+		{"multi-byte", `   string "caf%C3%A9%20%E2%80%94"`, "café —", true},
+		{"not encoded", `   string "50% done"`, "", false},
+		{"not a string", `   uint32 42`, "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := dbusPrintedLine(tt.in)
+			if got != tt.want || ok != tt.wantOk {
+				t.Errorf("dbusPrintedLine(%q) = %q, %v, want %q, %v", tt.in, got, ok, tt.want, tt.wantOk)
+			}
+		})
+	}
+}
@@ -39,10 +39,11 @@ func printEnvironment(env go_kwin6.Environment) {
 	for _, d := range ds {
 		fmt.Printf("\tX11 Number: %d; ", d.X11Number)
 		fmt.Printf("Name: %s; ", d.Name)
+		fmt.Printf("Windows: %d; ", d.WindowCount)
 		fmt.Printf("ID: %s\n", d.Id)
 	}
 	fmt.Printf("Windows: %d\n", len(env.Windows))
-	for _, w := range env.Windows {
+	for _, w := range env.WindowsSortedBy(go_kwin6.WindowsByCaption) {
 		fmt.Printf("Caption: %s\n", w.Caption)
 		fmt.Printf("\tID: %s\n", w.Id)
 		fmt.Printf("\tPID: %d\n", w.Pid)
@@ -56,17 +57,21 @@ func printEnvironment(env go_kwin6.Environment) {
 		fmt.Printf("KeepAbove: %t; ", w.KeepAbove)
 		fmt.Printf("KeepBelow: %t; ", w.KeepBelow)
 		fmt.Printf("Minimized: %t; ", w.Minimized)
+		fmt.Printf("Maximized H/V: %t/%t; ", w.MaximizedHorizontally, w.MaximizedVertically)
 		fmt.Printf("DemandsAttention: %t\n", w.DemandsAttention)
-		desktopNames := make([]string, len(w.Desktops))
-		for i, d := range w.Desktops {
-			desktopNames[i] = d.Name
+		if w.IsOnAllDesktops() {
+			fmt.Printf("\tOn desktops: all (%s)\n", strings.Join(w.DesktopNames(env), ", "))
+		} else {
+			fmt.Printf("\tOn desktops: %s\n", strings.Join(w.DesktopNames(env), ", "))
 		}
-		fmt.Printf("\tOn desktops: %s\n", strings.Join(desktopNames, ", "))
 	}
 }
 
 func main() {
 	kw := go_kwin6.NewKWin()
+	if err := kw.Validate(); err != nil {
+		log.Fatal(err)
+	}
 	env, err := kw.GetEnvironment()
 	if err != nil {
 		log.Fatal(err)
@@ -0,0 +1,29 @@
+package go_kwin6
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// CompositingActive reports whether KWin is currently compositing. Compositing can be suspended, e.g. by a fullscreen
+// game or by the user, in which case effects like opacity changes and animations don't apply. The state is read from
+// the active property of the org.kde.kwin.Compositing dbus interface, as KWin scripting doesn't expose it
+func (k KWin) CompositingActive() (bool, error) {
+	output, err := k.callDbusSend(context.Background(),
+		"--print-reply",
+		"--dest=org.kde.KWin",
+		"/Compositor", "org.freedesktop.DBus.Properties.Get",
+		"string:org.kde.kwin.Compositing", "string:active")
+	if err != nil {
+		fmt.Printf("Error getting compositing state: %v\n", err)
+		return false, err
+	}
+	for _, s := range output {
+		fields := strings.Fields(s)
+		if len(fields) >= 2 && fields[len(fields)-2] == "boolean" {
+			return fields[len(fields)-1] == "true", nil
+		}
+	}
+	return false, fmt.Errorf("compositing state not found in dbus reply")
+}
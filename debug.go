@@ -0,0 +1,42 @@
+package go_kwin6
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// LastScript returns the last generated script which failed to run or whose output could not be captured, or an empty
+// string when no script failed yet
+func (k KWin) LastScript() string {
+	if k.state == nil {
+		return ""
+	}
+	k.state.mu.Lock()
+	defer k.state.mu.Unlock()
+	return k.state.lastFailedScript
+}
+
+// ReRunLast runs the script returned by LastScript again, for debugging. The script is printed with line numbers
+// before it is run, the script file is kept (see KeepScriptFiles) and every output line is printed as well. The script
+// runs immediately, even on a Queued KWin, like every script which is not a void mutator
+func (k KWin) ReRunLast() ([]string, error) {
+	script := k.LastScript()
+	if script == "" {
+		return nil, fmt.Errorf("no failed script to run again")
+	}
+	for i, line := range strings.Split(script, "\n") {
+		fmt.Printf("%4d: %s\n", i+1, line)
+	}
+	verbose := k
+	verbose.KeepScriptFiles = true
+	output, err := verbose.loadExecuteAndGetOutputContext(context.Background(), script)
+	for _, s := range output {
+		fmt.Printf("Script output: %s\n", s)
+	}
+	if err != nil {
+		fmt.Printf("Error running script again: %v\n", err)
+		return nil, err
+	}
+	return output, nil
+}
@@ -0,0 +1,231 @@
+package go_kwin6
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+)
+
+// CreateDesktop will attempt to create a new virtual desktop with the given name at the given position (index). A
+// position past the end of the desktop list appends the desktop at the end
+func (k KWin) CreateDesktop(position int, name string) error {
+	script := `
+    position = %d;
+    name = "%s";
+    workspace.createDesktop(position, name);`
+	if position < 0 {
+		return fmt.Errorf("invalid desktop position: %d", position)
+	}
+	return k.runMutation(context.Background(), fmt.Sprintf(script, position, escapeJSString(name)))
+}
+
+// RemoveDesktop will attempt to remove a given Desktop. KWin does not allow removing the last remaining desktop, in
+// which case nothing happens.
+//
+//	NOTE: Windows which are only on the removed Desktop are moved to the Desktop preceding it (or to the following one
+//	when the first Desktop is removed). Windows which are also on other desktops just leave the removed one
+func (k KWin) RemoveDesktop(d Desktop) error {
+	script := `
+    targetDesktopId = "%s";
+    var index = -1;
+    for (var i = 0; i < workspace.desktops.length; i++) {
+        if (workspace.desktops[i].id === targetDesktopId) {
+            index = i;
+            break;
+        }
+    }
+    if (index >= 0 && workspace.desktops.length > 1) {
+        var desktop = workspace.desktops[index];
+        var fallback = workspace.desktops[index > 0 ? index-1 : 1];
+        for (const window of workspace.windowList()) {
+            if (window.onAllDesktops || !window.desktops.includes(desktop)) {
+                continue;
+            }
+            var remaining = window.desktops.filter(function(d) { return d.id !== desktop.id; });
+            if (remaining.length === 0) {
+                remaining = [fallback];
+            }
+            window.desktops = remaining;
+        }
+        workspace.removeDesktop(desktop);
+    }`
+	return k.runMutation(context.Background(), fmt.Sprintf(script, d.Id))
+}
+
+// SetDesktopCount will attempt to create or remove virtual desktops until there are exactly n of them. New desktops are
+// appended at the end with a default name and surplus desktops are removed from the end, so the first n desktops (and
+// their ids) are always kept.
+//
+//	NOTE: Windows on the removed desktops are migrated as described in RemoveDesktop, i.e. they end up on the last
+//	remaining desktop
+func (k KWin) SetDesktopCount(n int) error {
+	if n < 1 {
+		return fmt.Errorf("invalid desktop count: %d", n)
+	}
+	desktops, err := k.GetDesktops()
+	if err != nil {
+		fmt.Printf("Error getting desktops: %v\n", err)
+		return err
+	}
+	ds := make([]Desktop, len(desktops))
+	for _, d := range desktops {
+		ds[d.Index] = d
+	}
+	for i := len(ds); i < n; i++ {
+		err := k.CreateDesktop(i, fmt.Sprintf("Desktop %d", i+1))
+		if err != nil {
+			fmt.Printf("Error creating desktop: %v\n", err)
+			return err
+		}
+	}
+	for i := len(ds) - 1; i >= n; i-- {
+		err := k.RemoveDesktop(ds[i])
+		if err != nil {
+			fmt.Printf("Error removing desktop: %v\n", err)
+			return err
+		}
+	}
+	return nil
+}
+
+// CloseWindowsOnDesktop will attempt to close every Window on a given Desktop in a single script run, and returns the
+// number of windows which were asked to close. Applications may still refuse (e.g. to ask about unsaved changes).
+//
+//	NOTE: Windows which are on all desktops are skipped, as they are not really on just this Desktop. Special windows
+//	(panels, the desktop etc.) and windows which can't be closed are skipped too
+func (k KWin) CloseWindowsOnDesktop(d Desktop) (int, error) {
+	script := `
+    targetDesktopId = "%s";
+    var closed = 0;
+    for (const window of workspace.windowList()) {
+        if (window.onAllDesktops || window.specialWindow || !window.closeable) {
+            continue;
+        }
+        var onDesktop = false;
+        for (const desktop of window.desktops) {
+            if (desktop.id === targetDesktopId) {
+                onDesktop = true;
+                break;
+            }
+        }
+        if (onDesktop) {
+            window.closeWindow();
+            closed++;
+        }
+    }
+    print("{\"closed\": "+closed+"}");`
+	output, err := k.loadExecuteAndGetOutput(fmt.Sprintf(script, d.Id))
+	if err != nil {
+		fmt.Printf("Error running script for closing windows: %v\n", err)
+		return 0, err
+	}
+	if len(output) == 0 {
+		return 0, fmt.Errorf("no output from script for closing windows: %w", ErrNoScriptOutput)
+	}
+	if err := scriptError(output); err != nil {
+		return 0, err
+	}
+	result := struct {
+		Closed int `json:"closed"`
+	}{}
+	if err := json.Unmarshal([]byte(scriptLine(output[len(output)-1])), &result); err != nil {
+		return 0, err
+	}
+	return result.Closed, nil
+}
+
+// MoveWindowToNextDesktop will attempt to move a given Window to the Desktop following the one it is on. With wrap
+// set, a Window on the last Desktop goes to the first one, otherwise it stays where it is. A Window on several desktops
+// moves relative to the first of them. Windows on all desktops can't be moved this way and return an error
+func (k KWin) MoveWindowToNextDesktop(w Window, wrap bool) error {
+	return k.moveWindowToAdjacentDesktop(w, 1, wrap)
+}
+
+// MoveWindowToPreviousDesktop will attempt to move a given Window to the Desktop preceding the one it is on. With wrap
+// set, a Window on the first Desktop goes to the last one, otherwise it stays where it is. A Window on several desktops
+// moves relative to the first of them. Windows on all desktops can't be moved this way and return an error
+func (k KWin) MoveWindowToPreviousDesktop(w Window, wrap bool) error {
+	return k.moveWindowToAdjacentDesktop(w, -1, wrap)
+}
+
+// moveWindowToAdjacentDesktop moves a given Window by step desktops, see MoveWindowToNextDesktop
+func (k KWin) moveWindowToAdjacentDesktop(w Window, step int, wrap bool) error {
+	if w.OnAllDesktops {
+		return fmt.Errorf("window %s is on all desktops", w.Id)
+	}
+	desktops, err := k.GetDesktops()
+	if err != nil {
+		fmt.Printf("Error getting desktops: %v\n", err)
+		return err
+	}
+	ds := make([]Desktop, len(desktops))
+	for _, d := range desktops {
+		ds[d.Index] = d
+	}
+	current := -1
+	for _, id := range w.DesktopIds {
+		if d, ok := desktops[id]; ok && (current < 0 || d.Index < current) {
+			current = d.Index
+		}
+	}
+	if current < 0 {
+		return fmt.Errorf("window %s is on no known desktop", w.Id)
+	}
+	target := current + step
+	if target < 0 || target >= len(ds) {
+		if !wrap {
+			return nil
+		}
+		target = (target + len(ds)) % len(ds)
+	}
+	return k.MoveWindowToDesktop(w, ds[target])
+}
+
+// MoveDesktop will attempt to move a given Desktop to a new position (index) and returns the moved Desktop. KWin
+// scripting can't reorder desktops, so a new Desktop with the same name is created at the new position, the windows are
+// moved over to it (and it is made current, if the given Desktop was) and the given Desktop is removed. The moved
+// Desktop therefore has a new id. A position past the end of the desktop list moves the desktop to the end
+func (k KWin) MoveDesktop(d Desktop, newIndex int) (Desktop, error) {
+	script := desktopToJSON + `
+    sourceDesktopId = "%s";
+    var newIndex = %d;
+    var index = -1;
+    for (var i = 0; i < workspace.desktops.length; i++) {
+        if (workspace.desktops[i].id === sourceDesktopId) {
+            index = i;
+            break;
+        }
+    }
+    if (index >= 0) {
+        newIndex = Math.min(newIndex, workspace.desktops.length-1);
+        if (newIndex !== index) {
+            var source = workspace.desktops[index];
+            var position = newIndex > index ? newIndex+1 : newIndex;
+            workspace.createDesktop(position, source.name);
+            var target = workspace.desktops[position];
+            for (const window of workspace.windowList()) {
+                if (window.onAllDesktops || !window.desktops.includes(source)) {
+                    continue;
+                }
+                window.desktops = window.desktops.map(function(d) { return d.id === source.id ? target : d; });
+            }
+            if (workspace.currentDesktop.id === source.id) {
+                workspace.currentDesktop = target;
+            }
+            workspace.removeDesktop(source);
+        }
+        print(desktopToJSON(newIndex));
+    }`
+	if newIndex < 0 {
+		return Desktop{}, fmt.Errorf("invalid desktop position: %d", newIndex)
+	}
+	output, err := k.loadExecuteAndGetOutput(fmt.Sprintf(script, d.Id, newIndex))
+	if err != nil {
+		fmt.Printf("Error running script for moving desktop: %v\n", err)
+		return Desktop{}, err
+	}
+	if len(output) == 0 {
+		return Desktop{}, fmt.Errorf("desktop %s not found", d.Id)
+	}
+	return parseDesktopLine(output[len(output)-1])
+}
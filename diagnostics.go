@@ -0,0 +1,59 @@
+package go_kwin6
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+)
+
+// scriptNodePattern matches the child nodes of the /Scripting dbus object, one for every loaded script
+var scriptNodePattern = regexp.MustCompile(`<node name="Script(\d+)"\s*/>`)
+
+// GetLoadedScripts returns the registration numbers of all scripts currently loaded into KWin, by any program, in
+// ascending order. A script which stays loaded is usually a long-running one, e.g. a watch of this package or a script
+// of another tool which may interfere with the scripts run by this package
+func (k KWin) GetLoadedScripts() ([]int, error) {
+	output, err := k.callDbusSend(context.Background(),
+		"--print-reply",
+		"--dest=org.kde.KWin",
+		"/Scripting", "org.freedesktop.DBus.Introspectable.Introspect")
+	if err != nil {
+		fmt.Printf("Error introspecting scripts: %v\n", err)
+		return nil, err
+	}
+	scripts := make([]int, 0)
+	for _, s := range output {
+		for _, m := range scriptNodePattern.FindAllStringSubmatch(s, -1) {
+			scriptNo, err := strconv.Atoi(m[1])
+			if err != nil {
+				return nil, err
+			}
+			scripts = append(scripts, scriptNo)
+		}
+	}
+	sort.Ints(scripts)
+	return scripts, nil
+}
+
+// GetLoadedEffects returns the names of the KWin effects which are currently loaded, e.g. "blur", as reported by the
+// loadedEffects property of the org.kde.kwin.Effects dbus interface
+func (k KWin) GetLoadedEffects() ([]string, error) {
+	output, err := k.callDbusSend(context.Background(),
+		"--print-reply",
+		"--dest=org.kde.KWin",
+		"/Effects", "org.freedesktop.DBus.Properties.Get",
+		"string:org.kde.kwin.Effects", "string:loadedEffects")
+	if err != nil {
+		fmt.Printf("Error getting loaded effects: %v\n", err)
+		return nil, err
+	}
+	effects := make([]string, 0)
+	for _, s := range output {
+		if v, ok := dbusStringValue(s); ok {
+			effects = append(effects, v)
+		}
+	}
+	return effects, nil
+}
@@ -0,0 +1,399 @@
+package go_kwin6
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type (
+	// WindowChange is a struct that describes how a single Window, identified by its id, differs between two captured
+	// Environment objects
+	WindowChange struct {
+		Old Window `json:"old"`
+		New Window `json:"new"`
+		// Geometry is true when the window position or size changed
+		Geometry bool `json:"geometry"`
+		// Desktops is true when the window was moved to different desktops or pinned/unpinned from all desktops
+		Desktops bool `json:"desktops"`
+		// State is true when any of the state flags (fullscreen, minimized, maximized, keep above/below, demands
+		// attention) changed
+		State bool `json:"state"`
+	}
+	// EnvDiff is a struct that contains the differences between two captured Environment objects, as returned by
+	// DiffEnvironments. Objects are matched by their map keys, so a window which got a new id is reported as removed
+	// and added
+	EnvDiff struct {
+		AddedScreens    []Screen       `json:"addedScreens"`
+		RemovedScreens  []Screen       `json:"removedScreens"`
+		ChangedScreens  []Screen       `json:"changedScreens"`
+		AddedDesktops   []Desktop      `json:"addedDesktops"`
+		RemovedDesktops []Desktop      `json:"removedDesktops"`
+		ChangedDesktops []Desktop      `json:"changedDesktops"`
+		AddedWindows    []Window       `json:"addedWindows"`
+		RemovedWindows  []Window       `json:"removedWindows"`
+		ChangedWindows  []WindowChange `json:"changedWindows"`
+	}
+	// WindowsDelta is a struct that contains the windows which changed between two consecutive calls of
+	// GetWindowsChangedSince
+	WindowsDelta struct {
+		// Since is the time of the previous query the delta is relative to, it is zero for the first query
+		Since   time.Time      `json:"since"`
+		Added   []Window       `json:"added"`
+		Removed []Window       `json:"removed"`
+		Changed []WindowChange `json:"changed"`
+	}
+)
+
+// DiffEnvironments compares two captured Environment objects and returns the added, removed and changed screens,
+// desktops and windows. Changed screens and desktops are reported with their new values. The result slices are sorted by
+// screen name, desktop index and window id respectively, so the output is deterministic
+func DiffEnvironments(old, new Environment) EnvDiff {
+	diff := EnvDiff{}
+
+	for name, s := range old.Screens {
+		if _, ok := new.Screens[name]; !ok {
+			diff.RemovedScreens = append(diff.RemovedScreens, s)
+		}
+	}
+	for name, s := range new.Screens {
+		prev, ok := old.Screens[name]
+		if !ok {
+			diff.AddedScreens = append(diff.AddedScreens, s)
+		} else if !prev.Equal(s) {
+			diff.ChangedScreens = append(diff.ChangedScreens, s)
+		}
+	}
+
+	for id, d := range old.Desktops {
+		if _, ok := new.Desktops[id]; !ok {
+			diff.RemovedDesktops = append(diff.RemovedDesktops, d)
+		}
+	}
+	for id, d := range new.Desktops {
+		// Desktop.Equal leaves out the window count, which follows from the window changes
+		prev, ok := old.Desktops[id]
+		if !ok {
+			diff.AddedDesktops = append(diff.AddedDesktops, d)
+		} else if !prev.Equal(d) {
+			diff.ChangedDesktops = append(diff.ChangedDesktops, d)
+		}
+	}
+
+	for id, w := range old.Windows {
+		if _, ok := new.Windows[id]; !ok {
+			diff.RemovedWindows = append(diff.RemovedWindows, w)
+		}
+	}
+	for id, w := range new.Windows {
+		prev, ok := old.Windows[id]
+		if !ok {
+			diff.AddedWindows = append(diff.AddedWindows, w)
+			continue
+		}
+		geometryChanged := !floatEqual(prev.X, w.X) || !floatEqual(prev.Y, w.Y) ||
+			!floatEqual(prev.Width, w.Width) || !floatEqual(prev.Height, w.Height)
+		change := WindowChange{
+			Old:      prev,
+			New:      w,
+			Geometry: geometryChanged,
+			Desktops: prev.OnAllDesktops != w.OnAllDesktops || !sameElements(prev.DesktopIds, w.DesktopIds),
+			State: prev.Fullscreen != w.Fullscreen ||
+				prev.Minimized != w.Minimized ||
+				prev.MaximizedHorizontally != w.MaximizedHorizontally ||
+				prev.MaximizedVertically != w.MaximizedVertically ||
+				prev.KeepAbove != w.KeepAbove ||
+				prev.KeepBelow != w.KeepBelow ||
+				prev.DemandsAttention != w.DemandsAttention,
+		}
+		if change.Geometry || change.Desktops || change.State {
+			diff.ChangedWindows = append(diff.ChangedWindows, change)
+		}
+	}
+
+	sortScreens(diff.AddedScreens)
+	sortScreens(diff.RemovedScreens)
+	sortScreens(diff.ChangedScreens)
+	sortDesktops(diff.AddedDesktops)
+	sortDesktops(diff.RemovedDesktops)
+	sortDesktops(diff.ChangedDesktops)
+	sortWindows(diff.AddedWindows)
+	sortWindows(diff.RemovedWindows)
+	sort.Slice(diff.ChangedWindows, func(i, j int) bool {
+		return diff.ChangedWindows[i].New.Id < diff.ChangedWindows[j].New.Id
+	})
+	return diff
+}
+
+// Empty reports whether the diff contains no changes at all
+func (d EnvDiff) Empty() bool {
+	return len(d.AddedScreens) == 0 && len(d.RemovedScreens) == 0 && len(d.ChangedScreens) == 0 &&
+		len(d.AddedDesktops) == 0 && len(d.RemovedDesktops) == 0 && len(d.ChangedDesktops) == 0 &&
+		len(d.AddedWindows) == 0 && len(d.RemovedWindows) == 0 && len(d.ChangedWindows) == 0
+}
+
+// sameElements reports whether the two lists contain the same elements (e.g. desktop ids), regardless of their order
+func sameElements[T comparable](a, b []T) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	counts := make(map[T]int, len(a))
+	for _, e := range a {
+		counts[e]++
+	}
+	for _, e := range b {
+		if counts[e] == 0 {
+			return false
+		}
+		counts[e]--
+	}
+	return true
+}
+
+func sortScreens(s []Screen) {
+	sort.Slice(s, func(i, j int) bool {
+		return s[i].Name < s[j].Name
+	})
+}
+
+func sortDesktops(d []Desktop) {
+	sort.Slice(d, func(i, j int) bool {
+		return d[i].Index < d[j].Index
+	})
+}
+
+func sortWindows(w []Window) {
+	sort.Slice(w, func(i, j int) bool {
+		return w[i].Id < w[j].Id
+	})
+}
+
+// WindowsByDesktop groups the Windows of the Environment by the Desktop they are on, where the map key is the Desktop
+// uuid. Windows which are on all desktops are duplicated into the list of every Desktop of the Environment. Windows which
+// are not on all desktops but have no desktop assigned are grouped under the uuid.Nil key. Each list is sorted by window
+// id
+func (e Environment) WindowsByDesktop() map[uuid.UUID][]Window {
+	byDesktop := make(map[uuid.UUID][]Window, len(e.Desktops))
+	for _, w := range e.Windows {
+		switch {
+		case w.OnAllDesktops:
+			for id := range e.Desktops {
+				byDesktop[id] = append(byDesktop[id], w)
+			}
+		case len(w.DesktopIds) == 0:
+			byDesktop[uuid.Nil] = append(byDesktop[uuid.Nil], w)
+		default:
+			for _, id := range w.DesktopIds {
+				byDesktop[id] = append(byDesktop[id], w)
+			}
+		}
+	}
+	for id := range byDesktop {
+		sortWindows(byDesktop[id])
+	}
+	return byDesktop
+}
+
+// IsOnAllDesktops reports whether the Window is on all desktops, including the ones created later. A window which is on
+// every existing desktop, but was put there desktop by desktop, is not
+func (w Window) IsOnAllDesktops() bool {
+	return w.OnAllDesktops
+}
+
+// DesktopNames returns the names of the desktops the Window is on, resolved from its DesktopIds by the Desktops of the
+// given Environment, so it works when GetWindows was called without desktops. For windows on all desktops the names of
+// all desktops of the Environment are returned. The names are sorted by desktop position, ids which the Environment
+// doesn't know (e.g. a desktop removed in the meantime) are returned as the id itself, after the names
+func (w Window) DesktopNames(env Environment) []string {
+	ds := make([]Desktop, 0, len(w.DesktopIds))
+	unknown := make([]string, 0)
+	if w.OnAllDesktops {
+		for _, d := range env.Desktops {
+			ds = append(ds, d)
+		}
+	} else {
+		for _, id := range w.DesktopIds {
+			if d, ok := env.Desktops[id]; ok {
+				ds = append(ds, d)
+			} else {
+				unknown = append(unknown, id.String())
+			}
+		}
+	}
+	sortDesktops(ds)
+	names := make([]string, 0, len(ds)+len(unknown))
+	for _, d := range ds {
+		names = append(names, d.Name)
+	}
+	return append(names, unknown...)
+}
+
+// WindowGroups groups the Windows of the Environment by application, where the map key is the Window ResourceClass
+// (e.g. "firefox"). Windows which report no resource class are grouped under the empty string key. Each list is sorted
+// by window id
+func (e Environment) WindowGroups() map[string][]Window {
+	groups := make(map[string][]Window)
+	for _, w := range e.Windows {
+		groups[w.ResourceClass] = append(groups[w.ResourceClass], w)
+	}
+	for class := range groups {
+		sortWindows(groups[class])
+	}
+	return groups
+}
+
+// GetWindowsChangedSince returns the same map of Window objects as GetWindows, along with the windows which were added,
+// removed or changed since the previous call. KWin doesn't track per-window modification times, so the previous result
+// is cached in the KWin object and diffed against. On the first call every window is reported as added
+func (k KWin) GetWindowsChangedSince(desktops map[uuid.UUID]Desktop) (map[uuid.UUID]Window, WindowsDelta, error) {
+	if k.state == nil {
+		return nil, WindowsDelta{}, fmt.Errorf("window cache is not available, create the KWin object with NewKWin")
+	}
+	windows, err := k.GetWindows(desktops)
+	if err != nil {
+		return nil, WindowsDelta{}, err
+	}
+	now := k.now()
+
+	// The cache keeps its own copy, so changes the caller makes to the returned windows don't affect the next diff
+	k.state.mu.Lock()
+	previous, since := k.state.lastWindows, k.state.lastWindowsTime
+	k.state.lastWindows, k.state.lastWindowsTime = copyWindows(windows), now
+	k.state.mu.Unlock()
+
+	diff := DiffEnvironments(Environment{Windows: previous}, Environment{Windows: windows})
+	return windows, WindowsDelta{
+		Since:   since,
+		Added:   diff.AddedWindows,
+		Removed: diff.RemovedWindows,
+		Changed: diff.ChangedWindows,
+	}, nil
+}
+
+// copyWindows returns a copy of the given map of Window objects, which shares no slices or pointers with it
+func copyWindows(windows map[uuid.UUID]Window) map[uuid.UUID]Window {
+	copied := make(map[uuid.UUID]Window, len(windows))
+	for id, w := range windows {
+		w.DesktopIds = slices.Clone(w.DesktopIds)
+		w.Desktops = slices.Clone(w.Desktops)
+		w.Activities = slices.Clone(w.Activities)
+		if w.TransientForId != nil {
+			transientForId := *w.TransientForId
+			w.TransientForId = &transientForId
+		}
+		copied[id] = w
+	}
+	return copied
+}
+
+// countDesktopWindows sets the WindowCount of every Desktop of the Environment, counting the windows on all desktops in
+// every Desktop, and updates the Desktop copies referenced by the windows accordingly
+func (e Environment) countDesktopWindows() {
+	byDesktop := e.WindowsByDesktop()
+	for id, d := range e.Desktops {
+		d.WindowCount = len(byDesktop[id])
+		e.Desktops[id] = d
+	}
+	for _, w := range e.Windows {
+		for i := range w.Desktops {
+			if d, ok := e.Desktops[w.DesktopIds[i]]; ok {
+				w.Desktops[i] = d
+			}
+		}
+	}
+}
+
+// Validate checks the internal consistency of a captured Environment and returns the problems found, or nil when there
+// are none. It flags windows referencing desktops which are not in the Desktops map, windows whose Desktops list was not
+// resolved although they have DesktopIds, and screens with a zero-area geometry. Such problems usually mean the capture
+// was taken in the middle of a transition (e.g. a desktop being removed) and should be retried
+func (e Environment) Validate() []error {
+	var errs []error
+	for _, s := range e.Screens {
+		g := s.Geometry
+		if g.BottomRight.X <= g.TopLeft.X || g.BottomRight.Y <= g.TopLeft.Y {
+			errs = append(errs, fmt.Errorf("screen %s has zero-area geometry %+v", s.Name, g))
+		}
+	}
+	for _, w := range e.Windows {
+		for _, id := range w.DesktopIds {
+			if _, ok := e.Desktops[id]; !ok {
+				errs = append(errs, fmt.Errorf("window %s (%s) references unknown desktop %s", w.Id, w.Caption, id))
+			}
+		}
+		if len(w.DesktopIds) > 0 && len(w.Desktops) == 0 {
+			errs = append(errs, fmt.Errorf("window %s (%s) has desktop ids but no resolved desktops", w.Id, w.Caption))
+		}
+	}
+	if e.CurrentDesktopId != uuid.Nil {
+		if _, ok := e.Desktops[e.CurrentDesktopId]; !ok {
+			errs = append(errs, fmt.Errorf("current desktop %s is unknown", e.CurrentDesktopId))
+		}
+	}
+	return errs
+}
+
+// environmentRetryDelay is the time to wait before capturing the Environment again after an inconsistent capture
+const environmentRetryDelay = 200 * time.Millisecond
+
+// GetEnvironmentStable captures the Environment like GetEnvironment, validates it and retries the capture, up to the
+// given number of attempts, while it is internally inconsistent (see Environment.Validate). When all attempts yield an
+// inconsistent Environment, the last one is returned together with the validation problems joined into the error
+func (k KWin) GetEnvironmentStable(attempts int) (Environment, error) {
+	if attempts < 1 {
+		attempts = 1
+	}
+	var env Environment
+	var problems []error
+	for i := 0; i < attempts; i++ {
+		if i > 0 {
+			time.Sleep(environmentRetryDelay)
+		}
+		var err error
+		env, err = k.GetEnvironment()
+		if err != nil {
+			return Environment{}, err
+		}
+		problems = env.Validate()
+		if len(problems) == 0 {
+			return env, nil
+		}
+		fmt.Printf("Inconsistent environment capture, attempt %d of %d: %v\n", i+1, attempts, errors.Join(problems...))
+	}
+	return env, fmt.Errorf("environment still inconsistent after %d attempts: %w", attempts, errors.Join(problems...))
+}
+
+// WindowsSortedBy returns the Windows of the Environment as a slice, sorted by the given less function. Windows which
+// are equal according to less are ordered by their id, so the order is deterministic. WindowsByCaption, WindowsByPid and
+// WindowsByStackingOrder are ready-made less functions
+func (e Environment) WindowsSortedBy(less func(a, b Window) bool) []Window {
+	windows := make([]Window, 0, len(e.Windows))
+	for _, w := range e.Windows {
+		windows = append(windows, w)
+	}
+	sortWindows(windows)
+	sort.SliceStable(windows, func(i, j int) bool {
+		return less(windows[i], windows[j])
+	})
+	return windows
+}
+
+// WindowsByCaption orders windows by their caption, case-insensitively
+func WindowsByCaption(a, b Window) bool {
+	return strings.ToLower(a.Caption) < strings.ToLower(b.Caption)
+}
+
+// WindowsByPid orders windows by the process id which owns them
+func WindowsByPid(a, b Window) bool {
+	return a.Pid < b.Pid
+}
+
+// WindowsByStackingOrder orders windows from the bottom to the top of the stacking order
+func WindowsByStackingOrder(a, b Window) bool {
+	return a.StackingOrder < b.StackingOrder
+}
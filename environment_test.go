@@ -0,0 +1,195 @@
+package go_kwin6
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+var (
+	testDesktop1 = Desktop{Id: "5b1e3b2a-6f43-4a3e-9c1d-2f4e6a8b0c1d", Index: 0, Name: "Desktop 1", X11Number: 1}
+	testDesktop2 = Desktop{Id: "9d2c4e6f-1a3b-4c5d-8e7f-0a1b2c3d4e5f", Index: 1, Name: "Desktop 2", X11Number: 2}
+	testScreen1  = Screen{Name: "DP-1", Geometry: Rect{BottomRight: Point{2560, 1440}}, PixelRatio: 1}
+	testScreen2  = Screen{Name: "HDMI-A-1", Geometry: Rect{TopLeft: Point{2560, 0},
+		BottomRight: Point{4480, 1080}}, PixelRatio: 1}
+	testWindow1 = Window{Id: "0f6d1c3e-8a2b-4d5f-9e7a-1b3c5d7e9f02", Caption: "Editor", X: 0, Y: 0, Width: 1280,
+		Height: 1440, DesktopIds: []uuid.UUID{uuid.MustParse(testDesktop1.Id)}}
+	testWindow2 = Window{Id: "7a8b9c0d-1e2f-4a3b-8c4d-5e6f7a8b9c0d", Caption: "Terminal", X: 1280, Y: 0, Width: 1280,
+		Height: 1440, DesktopIds: []uuid.UUID{uuid.MustParse(testDesktop1.Id)}}
+)
+
+// testEnvironment returns an Environment holding the given screens, desktops and windows, keyed like GetEnvironment
+// does
+func testEnvironment(screens []Screen, desktops []Desktop, windows []Window) Environment {
+	env := Environment{
+		Screens:  make(map[string]Screen),
+		Desktops: make(map[uuid.UUID]Desktop),
+		Windows:  make(map[uuid.UUID]Window),
+	}
+	for _, s := range screens {
+		env.Screens[s.Name] = s
+	}
+	for _, d := range desktops {
+		env.Desktops[uuid.MustParse(d.Id)] = d
+	}
+	for _, w := range windows {
+		env.Windows[uuid.MustParse(w.Id)] = w
+	}
+	return env
+}
+
+func TestDiffEnvironmentsUnchanged(t *testing.T) {
+	env := testEnvironment([]Screen{testScreen1, testScreen2}, []Desktop{testDesktop1, testDesktop2},
+		[]Window{testWindow1, testWindow2})
+	same := testEnvironment([]Screen{testScreen1, testScreen2}, []Desktop{testDesktop1, testDesktop2},
+		[]Window{testWindow1, testWindow2})
+	// The window count follows from the window changes, so it is not a desktop change
+	d := same.Desktops[uuid.MustParse(testDesktop1.Id)]
+	d.WindowCount = 2
+	same.Desktops[uuid.MustParse(testDesktop1.Id)] = d
+	// Reordered desktop ids are the same desktop membership
+	w := testWindow1
+	w.DesktopIds = []uuid.UUID{uuid.MustParse(testDesktop2.Id), uuid.MustParse(testDesktop1.Id)}
+	env.Windows[uuid.MustParse(w.Id)] = w
+	w.DesktopIds = []uuid.UUID{uuid.MustParse(testDesktop1.Id), uuid.MustParse(testDesktop2.Id)}
+	same.Windows[uuid.MustParse(w.Id)] = w
+
+	if diff := DiffEnvironments(env, same); !diff.Empty() {
+		t.Errorf("DiffEnvironments() = %+v, want an empty diff", diff)
+	}
+}
+
+func TestDiffEnvironmentsAddedRemoved(t *testing.T) {
+	old := testEnvironment([]Screen{testScreen1}, []Desktop{testDesktop1}, []Window{testWindow1})
+	new := testEnvironment([]Screen{testScreen2}, []Desktop{testDesktop2}, []Window{testWindow2})
+	want := EnvDiff{
+		AddedScreens:    []Screen{testScreen2},
+		RemovedScreens:  []Screen{testScreen1},
+		AddedDesktops:   []Desktop{testDesktop2},
+		RemovedDesktops: []Desktop{testDesktop1},
+		AddedWindows:    []Window{testWindow2},
+		RemovedWindows:  []Window{testWindow1},
+	}
+	if diff := DiffEnvironments(old, new); !reflect.DeepEqual(diff, want) {
+		t.Errorf("DiffEnvironments() = %+v, want %+v", diff, want)
+	}
+	want = EnvDiff{
+		AddedScreens:    []Screen{testScreen1},
+		RemovedScreens:  []Screen{testScreen2},
+		AddedDesktops:   []Desktop{testDesktop1},
+		RemovedDesktops: []Desktop{testDesktop2},
+		AddedWindows:    []Window{testWindow1},
+		RemovedWindows:  []Window{testWindow2},
+	}
+	if diff := DiffEnvironments(new, old); !reflect.DeepEqual(diff, want) {
+		t.Errorf("DiffEnvironments() reversed = %+v, want %+v", diff, want)
+	}
+}
+
+func TestDiffEnvironmentsSorted(t *testing.T) {
+	old := testEnvironment(nil, nil, nil)
+	new := testEnvironment([]Screen{testScreen2, testScreen1}, []Desktop{testDesktop2, testDesktop1},
+		[]Window{testWindow2, testWindow1})
+	diff := DiffEnvironments(old, new)
+	if !reflect.DeepEqual(diff.AddedScreens, []Screen{testScreen1, testScreen2}) {
+		t.Errorf("AddedScreens = %+v, want them sorted by name", diff.AddedScreens)
+	}
+	if !reflect.DeepEqual(diff.AddedDesktops, []Desktop{testDesktop1, testDesktop2}) {
+		t.Errorf("AddedDesktops = %+v, want them sorted by index", diff.AddedDesktops)
+	}
+	if !reflect.DeepEqual(diff.AddedWindows, []Window{testWindow1, testWindow2}) {
+		t.Errorf("AddedWindows = %+v, want them sorted by id", diff.AddedWindows)
+	}
+}
+
+func TestDiffEnvironmentsIdChurn(t *testing.T) {
+	// A window (or desktop) which got a new id, e.g. after its program restarted, is matched by the map key only, so
+	// it is reported as removed and added, not as changed
+	restarted := testWindow1
+	restarted.Id = "3c4d5e6f-7a8b-4c9d-8e0f-1a2b3c4d5e6f"
+	recreated := testDesktop1
+	recreated.Id = "6e7f8a9b-0c1d-4e2f-9a3b-4c5d6e7f8a9b"
+	old := testEnvironment(nil, []Desktop{testDesktop1}, []Window{testWindow1})
+	new := testEnvironment(nil, []Desktop{recreated}, []Window{restarted})
+	want := EnvDiff{
+		AddedDesktops:   []Desktop{recreated},
+		RemovedDesktops: []Desktop{testDesktop1},
+		AddedWindows:    []Window{restarted},
+		RemovedWindows:  []Window{testWindow1},
+	}
+	if diff := DiffEnvironments(old, new); !reflect.DeepEqual(diff, want) {
+		t.Errorf("DiffEnvironments() = %+v, want %+v", diff, want)
+	}
+}
+
+func TestDiffEnvironmentsChangedScreensAndDesktops(t *testing.T) {
+	scaled := testScreen1
+	scaled.PixelRatio = 1.5
+	renamed := testDesktop2
+	renamed.Name = "Mail"
+	old := testEnvironment([]Screen{testScreen1, testScreen2}, []Desktop{testDesktop1, testDesktop2}, nil)
+	new := testEnvironment([]Screen{scaled, testScreen2}, []Desktop{testDesktop1, renamed}, nil)
+	want := EnvDiff{
+		ChangedScreens:  []Screen{scaled},
+		ChangedDesktops: []Desktop{renamed},
+	}
+	if diff := DiffEnvironments(old, new); !reflect.DeepEqual(diff, want) {
+		t.Errorf("DiffEnvironments() = %+v, want %+v", diff, want)
+	}
+}
+
+func TestDiffEnvironmentsChangedWindows(t *testing.T) {
+	desktop1 := uuid.MustParse(testDesktop1.Id)
+	desktop2 := uuid.MustParse(testDesktop2.Id)
+	tests := []struct {
+		name   string
+		change func(w *Window)
+		want   WindowChange
+	}{
+		{"moved", func(w *Window) { w.X = 100 }, WindowChange{Geometry: true}},
+		{"resized", func(w *Window) { w.Height = 720 }, WindowChange{Geometry: true}},
+		{"rounding noise", func(w *Window) { w.Width += 1e-9 }, WindowChange{}},
+		{"other desktop", func(w *Window) { w.DesktopIds = []uuid.UUID{desktop2} }, WindowChange{Desktops: true}},
+		{"added desktop", func(w *Window) { w.DesktopIds = append(w.DesktopIds, desktop2) },
+			WindowChange{Desktops: true}},
+		{"all desktops", func(w *Window) { w.OnAllDesktops = true }, WindowChange{Desktops: true}},
+		{"minimized", func(w *Window) { w.Minimized = true }, WindowChange{State: true}},
+		{"maximized", func(w *Window) { w.MaximizedHorizontally, w.MaximizedVertically = true, true },
+			WindowChange{State: true}},
+		{"fullscreen", func(w *Window) { w.Fullscreen = true }, WindowChange{State: true}},
+		{"keep above", func(w *Window) { w.KeepAbove = true }, WindowChange{State: true}},
+		{"demands attention", func(w *Window) { w.DemandsAttention = true }, WindowChange{State: true}},
+		{"caption only", func(w *Window) { w.Caption = "Editor - main.go" }, WindowChange{}},
+		{"minimized and moved", func(w *Window) {
+			w.Minimized = true
+			w.Y = 50
+			w.DesktopIds = []uuid.UUID{desktop1, desktop2}
+		}, WindowChange{Geometry: true, Desktops: true, State: true}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			changed := testWindow1
+			changed.DesktopIds = append([]uuid.UUID(nil), testWindow1.DesktopIds...)
+			tt.change(&changed)
+			old := testEnvironment(nil, nil, []Window{testWindow1, testWindow2})
+			new := testEnvironment(nil, nil, []Window{changed, testWindow2})
+			diff := DiffEnvironments(old, new)
+			if !tt.want.Geometry && !tt.want.Desktops && !tt.want.State {
+				if !diff.Empty() {
+					t.Errorf("DiffEnvironments() = %+v, want an empty diff", diff)
+				}
+				return
+			}
+			want := tt.want
+			want.Old = testWindow1
+			want.New = changed
+			if !reflect.DeepEqual(diff.ChangedWindows, []WindowChange{want}) {
+				t.Errorf("ChangedWindows = %+v, want %+v", diff.ChangedWindows, []WindowChange{want})
+			}
+			if len(diff.AddedWindows) != 0 || len(diff.RemovedWindows) != 0 {
+				t.Errorf("DiffEnvironments() = %+v, want no added or removed windows", diff)
+			}
+		})
+	}
+}
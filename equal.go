@@ -0,0 +1,122 @@
+package go_kwin6
+
+import (
+	"math"
+)
+
+const (
+	// IgnoreCaption ignores the window caption, which many programs change all the time (titles of web pages, clocks,
+	// progress etc.)
+	IgnoreCaption WindowFieldsIgnored = 1 << iota
+	// IgnoreStackingOrder ignores the stacking order, which changes whenever any other window is raised
+	IgnoreStackingOrder
+	// IgnoreDemandsAttention ignores the demands attention flag
+	IgnoreDemandsAttention
+	// IgnoreProcess ignores the process command line and application name, which are not read in every capture (see
+	// NoProcLookup and GetWindowsFields)
+	IgnoreProcess
+	// IgnoreVolatile are the fields Window.Equal ignores
+	IgnoreVolatile = IgnoreCaption | IgnoreStackingOrder | IgnoreDemandsAttention
+)
+
+// GeometryEpsilon is the tolerance used when comparing the float valued geometry (window position and size, screen
+// pixel ratio), as KWin may report a value like 1919.9999 for what is 1920 in another capture. Values closer than the
+// epsilon are equal. It is used by the Equal methods, DiffEnvironments, WatchScreens and ApplyRules, which doesn't
+// resize windows that already have the geometry of the rule
+var GeometryEpsilon = 0.01
+
+// WindowFieldsIgnored is a bitmask which selects the Window fields Window.EqualIgnoring leaves out of the comparison
+type WindowFieldsIgnored uint
+
+// floatEqual reports whether two geometry values are equal within GeometryEpsilon
+func floatEqual(a, b float64) bool {
+	return math.Abs(a-b) <= GeometryEpsilon
+}
+
+// hasGeometry reports whether the Window frame covers the given Rect, within GeometryEpsilon
+func (w Window) hasGeometry(r Rect) bool {
+	return floatEqual(w.X, float64(r.TopLeft.X)) &&
+		floatEqual(w.Y, float64(r.TopLeft.Y)) &&
+		floatEqual(w.Width, float64(r.BottomRight.X-r.TopLeft.X)) &&
+		floatEqual(w.Height, float64(r.BottomRight.Y-r.TopLeft.Y))
+}
+
+// Equal reports whether two rectangles cover the same area
+func (r Rect) Equal(other Rect) bool {
+	return r == other
+}
+
+// Equal reports whether two screens have the same properties, comparing the pixel ratio within GeometryEpsilon
+func (s Screen) Equal(other Screen) bool {
+	return s.Name == other.Name &&
+		s.Index == other.Index &&
+		s.Geometry.Equal(other.Geometry) &&
+		s.Manufacturer == other.Manufacturer &&
+		s.Model == other.Model &&
+		s.SerialNumber == other.SerialNumber &&
+		floatEqual(s.PixelRatio, other.PixelRatio) &&
+		s.Enabled == other.Enabled &&
+		s.Primary == other.Primary
+}
+
+// Equal reports whether two desktops have the same id, position, name and x11 number. The WindowCount is not compared,
+// as it depends on how the desktop was captured
+func (d Desktop) Equal(other Desktop) bool {
+	return d.Id == other.Id && d.Index == other.Index && d.Name == other.Name && d.X11Number == other.X11Number
+}
+
+// Equal reports whether two windows have the same properties, ignoring the volatile ones (IgnoreVolatile), like
+// EqualIgnoring does
+func (w Window) Equal(other Window) bool {
+	return w.EqualIgnoring(other, IgnoreVolatile)
+}
+
+// EqualIgnoring reports whether two windows have the same properties, except the ones selected by ignore. The geometry
+// is compared within GeometryEpsilon, the desktops and activities regardless of their order. The Desktops objects are
+// not compared, as they are resolved from the DesktopIds only when the desktops are passed to GetWindows
+func (w Window) EqualIgnoring(other Window, ignore WindowFieldsIgnored) bool {
+	if ignore&IgnoreCaption == 0 && w.Caption != other.Caption {
+		return false
+	}
+	if ignore&IgnoreStackingOrder == 0 && w.StackingOrder != other.StackingOrder {
+		return false
+	}
+	if ignore&IgnoreDemandsAttention == 0 && w.DemandsAttention != other.DemandsAttention {
+		return false
+	}
+	if ignore&IgnoreProcess == 0 && (w.CmdLine != other.CmdLine || w.AppName != other.AppName) {
+		return false
+	}
+	for _, f := range [][2]float64{
+		{w.X, other.X}, {w.Y, other.Y}, {w.Width, other.Width}, {w.Height, other.Height},
+		{w.MinWidth, other.MinWidth}, {w.MinHeight, other.MinHeight},
+		{w.MaxWidth, other.MaxWidth}, {w.MaxHeight, other.MaxHeight},
+	} {
+		if !floatEqual(f[0], f[1]) {
+			return false
+		}
+	}
+	transientEqual := (w.TransientForId == nil) == (other.TransientForId == nil) &&
+		(w.TransientForId == nil || *w.TransientForId == *other.TransientForId)
+	return w.Id == other.Id &&
+		w.Pid == other.Pid &&
+		w.ResourceClass == other.ResourceClass &&
+		w.ResourceName == other.ResourceName &&
+		w.Fullscreen == other.Fullscreen &&
+		w.OnAllDesktops == other.OnAllDesktops &&
+		w.KeepAbove == other.KeepAbove &&
+		w.KeepBelow == other.KeepBelow &&
+		w.Minimized == other.Minimized &&
+		w.SpecialWindow == other.SpecialWindow &&
+		w.MaximizedHorizontally == other.MaximizedHorizontally &&
+		w.MaximizedVertically == other.MaximizedVertically &&
+		sameElements(w.DesktopIds, other.DesktopIds) &&
+		sameElements(w.Activities, other.Activities) &&
+		w.RestoreGeometry.Equal(other.RestoreGeometry) &&
+		w.FrameGeometry.Equal(other.FrameGeometry) &&
+		w.ClientGeometry.Equal(other.ClientGeometry) &&
+		transientEqual &&
+		w.NoBorder == other.NoBorder &&
+		w.WindowType == other.WindowType &&
+		w.WindowRole == other.WindowRole
+}
@@ -0,0 +1,110 @@
+package go_kwin6
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// Format selects the serialization format of Environment.Export
+type Format int
+
+const (
+	// FormatJSON is the JSON representation of the Environment struct, on a single line
+	FormatJSON Format = iota
+	// FormatLines is a line oriented format meant for tools which don't parse JSON. Every line is one record of tab
+	// separated fields, the first field being the record type:
+	//
+	//	screen  name index left top right bottom pixelRatio
+	//	desktop id index name
+	//	window  id pid resourceClass desktopIds left top right bottom minimized fullscreen caption
+	//	active  activeWindowId currentDesktopId activeScreen
+	//
+	// where desktopIds is a comma separated list, "*" for windows on all desktops. Tabs and newlines in the text fields
+	// are replaced with spaces. The records are sorted by type in the above order, then by the screen name, desktop
+	// index or window id
+	FormatLines
+)
+
+// Export writes the Environment to w in the given Format
+func (e Environment) Export(w io.Writer, format Format) error {
+	switch format {
+	case FormatJSON:
+		return json.NewEncoder(w).Encode(e)
+	case FormatLines:
+		return e.exportLines(w)
+	default:
+		return fmt.Errorf("unknown export format: %d", format)
+	}
+}
+
+// exportLines writes the Environment to w in FormatLines
+func (e Environment) exportLines(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	spaces := strings.NewReplacer("\t", " ", "\n", " ", "\r", " ")
+	record := func(fields ...string) {
+		for i := range fields {
+			fields[i] = spaces.Replace(fields[i])
+		}
+		bw.WriteString(strings.Join(fields, "\t") + "\n")
+	}
+	rect := func(r Rect) []string {
+		return []string{
+			strconv.Itoa(r.TopLeft.X), strconv.Itoa(r.TopLeft.Y),
+			strconv.Itoa(r.BottomRight.X), strconv.Itoa(r.BottomRight.Y),
+		}
+	}
+
+	screens := make([]Screen, 0, len(e.Screens))
+	for _, s := range e.Screens {
+		screens = append(screens, s)
+	}
+	sortScreens(screens)
+	for _, s := range screens {
+		fields := append([]string{"screen", s.Name, strconv.Itoa(s.Index)}, rect(s.Geometry)...)
+		record(append(fields, strconv.FormatFloat(s.PixelRatio, 'g', -1, 64))...)
+	}
+
+	desktops := make([]Desktop, 0, len(e.Desktops))
+	for _, d := range e.Desktops {
+		desktops = append(desktops, d)
+	}
+	sortDesktops(desktops)
+	for _, d := range desktops {
+		record("desktop", d.Id, strconv.Itoa(d.Index), d.Name)
+	}
+
+	windows := make([]Window, 0, len(e.Windows))
+	for _, w := range e.Windows {
+		windows = append(windows, w)
+	}
+	sortWindows(windows)
+	for _, w := range windows {
+		desktopIds := "*"
+		if !w.OnAllDesktops {
+			ids := make([]string, len(w.DesktopIds))
+			for i, id := range w.DesktopIds {
+				ids[i] = id.String()
+			}
+			desktopIds = strings.Join(ids, ",")
+		}
+		fields := append([]string{"window", w.Id, strconv.Itoa(w.Pid), w.ResourceClass, desktopIds}, rect(w.Geometry())...)
+		record(append(fields, strconv.FormatBool(w.Minimized), strconv.FormatBool(w.Fullscreen), w.Caption)...)
+	}
+
+	activeWindowId := ""
+	if e.ActiveWindowId != uuid.Nil {
+		activeWindowId = e.ActiveWindowId.String()
+	}
+	currentDesktopId := ""
+	if e.CurrentDesktopId != uuid.Nil {
+		currentDesktopId = e.CurrentDesktopId.String()
+	}
+	record("active", activeWindowId, currentDesktopId, e.ActiveScreen)
+	return bw.Flush()
+}
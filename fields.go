@@ -0,0 +1,141 @@
+package go_kwin6
+
+import (
+	"fmt"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// FieldIdentity selects the caption, pid, resource name and class, window type and role and the transient parent
+	FieldIdentity WindowFields = 1 << iota
+	// FieldGeometry selects the position, size, size constraints and the frame, client and restore geometry
+	FieldGeometry
+	// FieldState selects the desktops, activities, stacking order and the state flags (minimized, maximized etc.)
+	FieldState
+	// FieldProcess selects the process command line and application name, which are read from /proc. It needs
+	// FieldIdentity for the pid
+	FieldProcess
+	// AllFields selects everything, like GetWindows does
+	AllFields = FieldIdentity | FieldGeometry | FieldState | FieldProcess
+)
+
+// WindowFields is a bitmask which selects the Window fields GetWindowsFields populates. The window id is always set
+type WindowFields uint
+
+// windowFieldsJS are the JavaScript statements which add the properties of each field group of a KWin::Window, in the
+// windowToJSON representation, to the parts array
+var windowFieldsJS = map[WindowFields]string{
+	FieldIdentity: `
+		parts.push("\"caption\": "+JSON.stringify(window.caption));
+		parts.push("\"pid\": "+window.pid);
+		parts.push("\"resourceName\": "+JSON.stringify(window.resourceName));
+		parts.push("\"resourceClass\": "+JSON.stringify(window.resourceClass));
+		parts.push("\"windowType\": \""+windowType(window)+"\"");
+		parts.push("\"windowRole\": "+JSON.stringify(window.windowRole || ""));
+		var transientFor = window.transientFor;
+		if (transientFor) {
+			parts.push("\"transientForId\": \""+transientFor.internalId.toString().replace(/{/, "").replace(/}/, "")+"\"");
+		}`,
+	FieldGeometry: `
+		parts.push("\"x\": "+window.x);
+		parts.push("\"y\": "+window.y);
+		parts.push("\"width\": "+window.width);
+		parts.push("\"height\": "+window.height);
+		var minSize = window.minSize || {width: 0, height: 0};
+		var maxSize = window.maxSize || {width: 0, height: 0};
+		parts.push("\"minWidth\": "+minSize.width);
+		parts.push("\"minHeight\": "+minSize.height);
+		parts.push("\"maxWidth\": "+maxSize.width);
+		parts.push("\"maxHeight\": "+maxSize.height);
+		var restore = window.geometryRestore;
+		if (!restore || restore.width <= 0 || restore.height <= 0) {
+			restore = window.frameGeometry;
+		}
+		parts.push("\"restoreGeometry\": "+rectToJSON(restore));
+		parts.push("\"frameGeometry\": "+rectToJSON(window.frameGeometry));
+		parts.push("\"clientGeometry\": "+rectToJSON(window.clientGeometry || window.frameGeometry));`,
+	FieldState: `
+		parts.push("\"fullScreen\": "+window.fullScreen);
+		parts.push("\"onAllDesktops\": "+window.onAllDesktops);
+		parts.push("\"keepAbove\": "+window.keepAbove);
+		parts.push("\"keepBelow\": "+window.keepBelow);
+		parts.push("\"minimized\": "+window.minimized);
+		parts.push("\"specialWindow\": "+window.specialWindow);
+		var maximizeMode = windowMaximizeMode(window);
+		parts.push("\"maximizedVertically\": "+((maximizeMode & 1) !== 0));
+		parts.push("\"maximizedHorizontally\": "+((maximizeMode & 2) !== 0));
+		parts.push("\"demandsAttention\": "+window.demandsAttention);
+		parts.push("\"noBorder\": "+(window.noBorder === true));
+		parts.push("\"activities\": "+JSON.stringify(window.activities || []));
+		var stackingOrder = window.stackingOrder;
+		if (stackingOrder === undefined) {
+			stackingOrder = workspace.stackingOrder.indexOf(window);
+		}
+		parts.push("\"stackingOrder\": "+stackingOrder);
+		parts.push("\"desktopIds\": "+JSON.stringify(window.desktops.map(function(d) { return d.id; })));`,
+}
+
+// toJS returns a JavaScript function windowFieldsToJSON(window), which serializes only the selected field groups of a
+// KWin::Window, reading no other property. The window id is always included
+func (f WindowFields) toJS() string {
+	script := `
+	function windowFieldsToJSON(window) {
+		var parts = ["\"id\": \""+window.internalId.toString().replace(/{/, "").replace(/}/, "")+"\""];`
+	for _, field := range []WindowFields{FieldIdentity, FieldGeometry, FieldState} {
+		if f&field != 0 {
+			script += windowFieldsJS[field]
+		}
+	}
+	return script + `
+		return "{"+parts.join(",")+"}";
+	}`
+}
+
+// GetWindowsFields returns the same windows as GetWindows, but with only the selected fields populated, the others are
+// left at their zero value. The script reads and prints only the properties of the selected fields and the process
+// information is only read from /proc with FieldProcess, so e.g. a status bar asking for FieldIdentity|FieldState gets
+// the captions and desktops quicker than with GetWindows
+func (k KWin) GetWindowsFields(desktops map[uuid.UUID]Desktop, fields WindowFields) (map[uuid.UUID]Window, error) {
+	script := windowJSONHelpers + fields.toJS() + WindowFilter{}.toJS() + `
+	for (const window of workspace.windowList()) {
+		if (!windowMatchesFilter(window)) {
+			continue;
+		}
+		print(windowFieldsToJSON(window))
+	}`
+	output, err := k.loadExecuteAndGetOutput(script)
+	if err != nil {
+		fmt.Printf("Error running script for windows list: %v\n", err)
+		return nil, err
+	}
+	if err := scriptError(output); err != nil {
+		return nil, err
+	}
+	parsed := make([]Window, 0, len(output))
+	pids := make([]int, 0, len(output))
+	for _, s := range output {
+		w, err := parseWindowLine(s)
+		if err != nil {
+			return nil, err
+		}
+		parsed = append(parsed, w)
+		pids = append(pids, w.Pid)
+	}
+	enricher := k
+	var cmdLines map[int]processCmdLine
+	if fields&FieldProcess != 0 {
+		cmdLines = k.getProcessCmdLines(pids)
+	} else {
+		enricher.NoProcLookup = true
+	}
+	windows := make(map[uuid.UUID]Window, len(parsed))
+	for _, w := range parsed {
+		w, err := enricher.enrichWindow(w, desktops, cmdLines)
+		if err != nil {
+			return nil, err
+		}
+		windows[uuid.MustParse(w.Id)] = w
+	}
+	return windows, nil
+}
@@ -0,0 +1,137 @@
+package go_kwin6
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// WindowFilter is a struct that describes which windows to select. Every non-zero field must match for a Window to be
+// selected, so the zero value WindowFilter selects all windows, except the special ones (desktop, panels,
+// notifications etc.), like GetWindows does. String fields are compared case-insensitively
+type WindowFilter struct {
+	// ResourceClass matches the window resource class exactly, e.g. "firefox"
+	ResourceClass string `json:"resourceClass,omitempty"`
+	// ResourceName matches the window resource name exactly
+	ResourceName string `json:"resourceName,omitempty"`
+	// AppName matches the application name, derived from the process command line, exactly
+	AppName string `json:"appName,omitempty"`
+	// CaptionContains matches windows whose caption contains the given text
+	CaptionContains string `json:"captionContains,omitempty"`
+	// Pid matches the process id which owns the window
+	Pid int `json:"pid,omitempty"`
+	// DesktopId matches windows which are on the given desktop, including the windows on all desktops
+	DesktopId uuid.UUID `json:"desktopId,omitempty"`
+	// IncludeSpecial selects the special windows as well
+	IncludeSpecial bool `json:"includeSpecial,omitempty"`
+}
+
+// IsZero reports whether the filter has no conditions set, i.e. it selects all (but the special) windows
+func (f WindowFilter) IsZero() bool {
+	return f == WindowFilter{}
+}
+
+// Matches reports whether the given Window satisfies all conditions of the filter
+func (f WindowFilter) Matches(w Window) bool {
+	if !f.IncludeSpecial && w.SpecialWindow {
+		return false
+	}
+	if f.ResourceClass != "" && !strings.EqualFold(f.ResourceClass, w.ResourceClass) {
+		return false
+	}
+	if f.ResourceName != "" && !strings.EqualFold(f.ResourceName, w.ResourceName) {
+		return false
+	}
+	if f.AppName != "" && !strings.EqualFold(f.AppName, w.AppName) {
+		return false
+	}
+	if f.CaptionContains != "" && !strings.Contains(strings.ToLower(w.Caption), strings.ToLower(f.CaptionContains)) {
+		return false
+	}
+	if f.Pid != 0 && f.Pid != w.Pid {
+		return false
+	}
+	if f.DesktopId != uuid.Nil && !w.OnAllDesktops {
+		found := false
+		for _, id := range w.DesktopIds {
+			if id == f.DesktopId {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// Filter returns the windows of the given map which satisfy the filter, sorted by window id
+func (f WindowFilter) Filter(windows map[uuid.UUID]Window) []Window {
+	matching := make([]Window, 0)
+	for _, w := range windows {
+		if f.Matches(w) {
+			matching = append(matching, w)
+		}
+	}
+	sortWindows(matching)
+	return matching
+}
+
+// toJS returns a JavaScript function windowMatchesFilter(window), which evaluates the filter conditions that can be
+// checked inside a script (all but AppName, which is derived from the process command line on the Go side)
+func (f WindowFilter) toJS() string {
+	script := `
+	var filter = %s;
+	function windowMatchesFilter(window) {
+		if (!filter.includeSpecial && window.specialWindow) {
+			return false;
+		}
+		if (filter.resourceClass !== "" && window.resourceClass.toLowerCase() !== filter.resourceClass) {
+			return false;
+		}
+		if (filter.resourceName !== "" && window.resourceName.toLowerCase() !== filter.resourceName) {
+			return false;
+		}
+		if (filter.captionContains !== "" && !window.caption.toLowerCase().includes(filter.captionContains)) {
+			return false;
+		}
+		if (filter.pid !== 0 && window.pid !== filter.pid) {
+			return false;
+		}
+		if (filter.desktopId !== "" && !window.onAllDesktops) {
+			var found = false;
+			for (const d of window.desktops) {
+				if (d.id === filter.desktopId) {
+					found = true;
+				}
+			}
+			if (!found) {
+				return false;
+			}
+		}
+		return true;
+	}`
+	desktopId := ""
+	if f.DesktopId != uuid.Nil {
+		desktopId = f.DesktopId.String()
+	}
+	filter, _ := json.Marshal(struct {
+		ResourceClass   string `json:"resourceClass"`
+		ResourceName    string `json:"resourceName"`
+		CaptionContains string `json:"captionContains"`
+		Pid             int    `json:"pid"`
+		DesktopId       string `json:"desktopId"`
+		IncludeSpecial  bool   `json:"includeSpecial"`
+	}{
+		ResourceClass:   strings.ToLower(f.ResourceClass),
+		ResourceName:    strings.ToLower(f.ResourceName),
+		CaptionContains: strings.ToLower(f.CaptionContains),
+		Pid:             f.Pid,
+		DesktopId:       desktopId,
+		IncludeSpecial:  f.IncludeSpecial,
+	})
+	return fmt.Sprintf(script, filter)
+}
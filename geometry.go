@@ -0,0 +1,160 @@
+package go_kwin6
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/google/uuid"
+)
+
+// All geometry reported by this package (Screen.Geometry, the Window X, Y, Width and Height, cursor position etc.) is in
+// logical, device-independent pixels of the global compositor space, which KWin uses for all screens regardless of
+// their scaling. A Screen with a PixelRatio of 2 and a Geometry 1920 wide therefore has 3840 physical pixels across.
+// Use Window.PhysicalGeometry when the physical pixels of a particular screen are needed. Fractional ratios (1.25, 1.5)
+// don't change this: layout math is done in logical pixels and only the conversion to physical pixels rounds
+
+// Geometry returns the Window frame position and size as a Rect in logical pixels, where BottomRight is the first point
+// past the window, i.e. TopLeft plus the window size. The coordinates are rounded to the nearest integer
+func (w Window) Geometry() Rect {
+	return Rect{
+		TopLeft: Point{
+			X: int(math.Round(w.X)),
+			Y: int(math.Round(w.Y)),
+		},
+		BottomRight: Point{
+			X: int(math.Round(w.X + w.Width)),
+			Y: int(math.Round(w.Y + w.Height)),
+		},
+	}
+}
+
+// PhysicalGeometry converts the Window geometry from logical pixels into the physical pixels of the given Screen. As
+// screens with different pixel ratios don't share a physical coordinate space, the result is relative to the top left
+// corner of the Screen, i.e. the point (0, 0) is the first physical pixel of the Screen. The coordinates are rounded to
+// the nearest integer
+func (w Window) PhysicalGeometry(s Screen) Rect {
+	ratio := s.PixelRatio
+	if ratio <= 0 {
+		ratio = 1
+	}
+	originX := float64(s.Geometry.TopLeft.X)
+	originY := float64(s.Geometry.TopLeft.Y)
+	return Rect{
+		TopLeft: Point{
+			X: int(math.Round((w.X - originX) * ratio)),
+			Y: int(math.Round((w.Y - originY) * ratio)),
+		},
+		BottomRight: Point{
+			X: int(math.Round((w.X + w.Width - originX) * ratio)),
+			Y: int(math.Round((w.Y + w.Height - originY) * ratio)),
+		},
+	}
+}
+
+// LayoutGeometry returns the geometry which describes where the Window belongs in a layout. For minimized or maximized
+// windows this is the RestoreGeometry, i.e. where the window returns to when restored, as their current geometry is not
+// meaningful for a layout (minimized windows may even report stale or zero geometry). For all other windows it is the
+// current Geometry
+func (w Window) LayoutGeometry() Rect {
+	if w.Minimized || w.MaximizedHorizontally || w.MaximizedVertically {
+		return w.RestoreGeometry
+	}
+	return w.Geometry()
+}
+
+// ConstrainSize clamps the given window size to the minimum and maximum size the Window accepts, so layout math can
+// compute valid target sizes up front. A zero constraint is treated as no constraint
+func (w Window) ConstrainSize(width, height float64) (float64, float64) {
+	if w.MaxWidth > 0 && width > w.MaxWidth {
+		width = w.MaxWidth
+	}
+	if w.MaxHeight > 0 && height > w.MaxHeight {
+		height = w.MaxHeight
+	}
+	if width < w.MinWidth {
+		width = w.MinWidth
+	}
+	if height < w.MinHeight {
+		height = w.MinHeight
+	}
+	return width, height
+}
+
+// rememberGeometries records the geometry of the given windows in the KWin object cache used by LastKnownGeometry.
+// Minimized windows are skipped, so the cache keeps the geometry they had before being minimized
+func (k KWin) rememberGeometries(windows map[uuid.UUID]Window) {
+	if k.state == nil {
+		return
+	}
+	k.state.mu.Lock()
+	defer k.state.mu.Unlock()
+	for id, w := range windows {
+		if w.Minimized {
+			continue
+		}
+		k.state.lastGeometries[id] = w.Geometry()
+	}
+}
+
+// LastKnownGeometry returns the last geometry seen for the Window with the given id by any of the window listing calls
+// (GetWindows, GetEnvironment etc.) of this KWin object, while the window was not minimized. KWin scripting doesn't
+// expose the per-desktop positions it may remember, so the geometry is cached on the Go side and survives the window
+// being minimized, moved to another desktop or closed. The second return value is false when no geometry was seen yet
+func (k KWin) LastKnownGeometry(id uuid.UUID) (Rect, bool) {
+	if k.state == nil {
+		return Rect{}, false
+	}
+	k.state.mu.Lock()
+	defer k.state.mu.Unlock()
+	r, ok := k.state.lastGeometries[id]
+	return r, ok
+}
+
+// FractionRect converts a rectangle described by fractions (0..1) of the Screen geometry into absolute coordinates.
+// Both the Screen geometry and the result are in logical pixels, so the PixelRatio of the Screen needs no correction:
+// the left half of a 2x scaled screen is the left half in logical pixels too. The result is rounded to whole pixels and
+// clipped to the Screen
+func (s Screen) FractionRect(x, y, width, height float64) (Rect, error) {
+	for _, f := range []float64{x, y, width, height} {
+		if math.IsNaN(f) || f < 0 || f > 1 {
+			return Rect{}, fmt.Errorf("invalid screen fraction: %v", f)
+		}
+	}
+	if width == 0 || height == 0 {
+		return Rect{}, fmt.Errorf("empty screen fraction: %vx%v", width, height)
+	}
+	originX := float64(s.Geometry.TopLeft.X)
+	originY := float64(s.Geometry.TopLeft.Y)
+	screenWidth := float64(s.Geometry.BottomRight.X - s.Geometry.TopLeft.X)
+	screenHeight := float64(s.Geometry.BottomRight.Y - s.Geometry.TopLeft.Y)
+	return Rect{
+		TopLeft: Point{
+			X: int(math.Round(originX + x*screenWidth)),
+			Y: int(math.Round(originY + y*screenHeight)),
+		},
+		BottomRight: Point{
+			X: int(math.Round(originX + math.Min(x+width, 1)*screenWidth)),
+			Y: int(math.Round(originY + math.Min(y+height, 1)*screenHeight)),
+		},
+	}, nil
+}
+
+// CenteredGeometry returns the geometry the Window would have when centered on the given Screen, keeping its current
+// size. As both are in logical pixels, the result is correct for any PixelRatio of the Screen. The coordinates are
+// rounded to the nearest integer
+func (w Window) CenteredGeometry(s Screen) Rect {
+	screenWidth := float64(s.Geometry.BottomRight.X - s.Geometry.TopLeft.X)
+	screenHeight := float64(s.Geometry.BottomRight.Y - s.Geometry.TopLeft.Y)
+	x := math.Round(float64(s.Geometry.TopLeft.X) + (screenWidth-w.Width)/2)
+	y := math.Round(float64(s.Geometry.TopLeft.Y) + (screenHeight-w.Height)/2)
+	return Rect{
+		TopLeft: Point{
+			X: int(x),
+			Y: int(y),
+		},
+		BottomRight: Point{
+			X: int(math.Round(x + w.Width)),
+			Y: int(math.Round(y + w.Height)),
+		},
+	}
+}
@@ -0,0 +1,95 @@
+package go_kwin6
+
+import (
+	"testing"
+)
+
+// scaledScreens are screens with fractional and integer pixel ratios, each with the logical Geometry KWin reports for
+// it, placed like in a multi-monitor setup so the screen origin is not (0, 0) everywhere
+var scaledScreens = map[float64]Screen{
+	// A 3840x2160 panel at 125%, right of a 1920 pixels wide screen
+	1.25: {Name: "DP-2", PixelRatio: 1.25, Geometry: Rect{TopLeft: Point{1920, 0}, BottomRight: Point{4992, 1728}}},
+	// A 3840x2160 panel at 150%
+	1.5: {Name: "DP-1", PixelRatio: 1.5, Geometry: Rect{TopLeft: Point{0, 0}, BottomRight: Point{2560, 1440}}},
+	// A 3840x2160 panel at 200%, below a 1440 pixels high screen
+	2.0: {Name: "eDP-1", PixelRatio: 2, Geometry: Rect{TopLeft: Point{0, 1440}, BottomRight: Point{1920, 2520}}},
+}
+
+func TestCenteredGeometry(t *testing.T) {
+	// An odd width puts the centered position on a half pixel, which is rounded away from zero
+	w := Window{Width: 1001, Height: 600}
+	tests := []struct {
+		ratio    float64
+		want     Rect
+		physical Rect
+	}{
+		{1.25, Rect{Point{2956, 564}, Point{3957, 1164}}, Rect{Point{1295, 705}, Point{2546, 1455}}},
+		{1.5, Rect{Point{780, 420}, Point{1781, 1020}}, Rect{Point{1170, 630}, Point{2672, 1530}}},
+		{2.0, Rect{Point{460, 1680}, Point{1461, 2280}}, Rect{Point{920, 480}, Point{2922, 1680}}},
+	}
+	for _, tt := range tests {
+		s := scaledScreens[tt.ratio]
+		got := w.CenteredGeometry(s)
+		if got != tt.want {
+			t.Errorf("CenteredGeometry() at %v = %v, want %v", tt.ratio, got, tt.want)
+		}
+		// The logical result must not depend on the pixel ratio
+		unscaled := s
+		unscaled.PixelRatio = 1
+		if other := w.CenteredGeometry(unscaled); other != got {
+			t.Errorf("CenteredGeometry() at %v = %v, but %v at 1", tt.ratio, got, other)
+		}
+		centered := w
+		centered.X = float64(got.TopLeft.X)
+		centered.Y = float64(got.TopLeft.Y)
+		if physical := centered.PhysicalGeometry(s); physical != tt.physical {
+			t.Errorf("PhysicalGeometry() of centered window at %v = %v, want %v", tt.ratio, physical, tt.physical)
+		}
+	}
+}
+
+func TestFractionRect(t *testing.T) {
+	tests := []struct {
+		name                string
+		ratio               float64
+		x, y, width, height float64
+		want                Rect
+	}{
+		{"left half 1.25", 1.25, 0, 0, 0.5, 1, Rect{Point{1920, 0}, Point{3456, 1728}}},
+		{"right 60% 1.25", 1.25, 0.4, 0, 0.6, 1, Rect{Point{3149, 0}, Point{4992, 1728}}},
+		{"top left third 1.25", 1.25, 0, 0, 1.0 / 3, 0.5, Rect{Point{1920, 0}, Point{2944, 864}}},
+		{"left half 1.5", 1.5, 0, 0, 0.5, 1, Rect{Point{0, 0}, Point{1280, 1440}}},
+		{"right 60% 1.5", 1.5, 0.4, 0, 0.6, 1, Rect{Point{1024, 0}, Point{2560, 1440}}},
+		{"top left third 1.5", 1.5, 0, 0, 1.0 / 3, 0.5, Rect{Point{0, 0}, Point{853, 720}}},
+		{"left half 2.0", 2.0, 0, 0, 0.5, 1, Rect{Point{0, 1440}, Point{960, 2520}}},
+		{"right 60% 2.0", 2.0, 0.4, 0, 0.6, 1, Rect{Point{768, 1440}, Point{1920, 2520}}},
+		{"top left third 2.0", 2.0, 0, 0, 1.0 / 3, 0.5, Rect{Point{0, 1440}, Point{640, 1980}}},
+		{"clipped 2.0", 2.0, 0.75, 0.75, 0.5, 0.5, Rect{Point{1440, 2250}, Point{1920, 2520}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := scaledScreens[tt.ratio]
+			got, err := s.FractionRect(tt.x, tt.y, tt.width, tt.height)
+			if err != nil {
+				t.Fatalf("FractionRect() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("FractionRect() = %v, want %v", got, tt.want)
+			}
+			unscaled := s
+			unscaled.PixelRatio = 1
+			if other, _ := unscaled.FractionRect(tt.x, tt.y, tt.width, tt.height); other != got {
+				t.Errorf("FractionRect() = %v, but %v at pixel ratio 1", got, other)
+			}
+		})
+	}
+}
+
+func TestFractionRectInvalid(t *testing.T) {
+	s := scaledScreens[1.5]
+	for _, f := range [][4]float64{{-0.1, 0, 0.5, 1}, {0, 0, 1.5, 1}, {0, 0, 0, 1}, {0, 0, 1, 0}} {
+		if _, err := s.FractionRect(f[0], f[1], f[2], f[3]); err == nil {
+			t.Errorf("FractionRect(%v) error = nil, want an error", f)
+		}
+	}
+}
@@ -0,0 +1,40 @@
+package go_kwin6
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestJournalWindowArgs(t *testing.T) {
+	start := time.Date(2024, 3, 9, 14, 5, 7, 123456789, time.UTC)
+	tick := start
+	k := KWin{Clock: func() time.Time {
+		now := tick
+		tick = tick.Add(250 * time.Millisecond)
+		return now
+	}}
+	from := k.now()
+	to := k.now()
+	want := []string{"QT_CATEGORY=js", "QT_CATEGORY=kwin_scripting", "-o", "cat", "--boot",
+		"--since", "2024-03-09 14:05:07.123456", "--until", "2024-03-09 14:05:07.373456", "--no-pager", "--quiet"}
+	if got := k.journalWindowArgs(from, to); !reflect.DeepEqual(got, want) {
+		t.Errorf("journalWindowArgs() = %q, want %q", got, want)
+	}
+	if got := from.Format(journalTimeFormat); got != "2024-03-09 14:05:07.123456" {
+		t.Errorf("Clock time formatted = %q, want microsecond precision", got)
+	}
+}
+
+func TestJournalWindowArgsScope(t *testing.T) {
+	at := time.Date(2024, 12, 31, 23, 59, 59, 999999000, time.UTC)
+	k := KWin{JournalAllBoots: true, JournalUserUnit: "plasma-kwin_wayland.service", Clock: func() time.Time {
+		return at
+	}}
+	want := []string{"QT_CATEGORY=js", "QT_CATEGORY=kwin_scripting", "-o", "cat",
+		"--user-unit", "plasma-kwin_wayland.service",
+		"--since", "2024-12-31 23:59:59.999999", "--until", "2024-12-31 23:59:59.999999", "--no-pager", "--quiet"}
+	if got := k.journalWindowArgs(k.now(), k.now()); !reflect.DeepEqual(got, want) {
+		t.Errorf("journalWindowArgs() = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,101 @@
+package go_kwin6
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+)
+
+// kscreenDoctor is the KScreen command line tool, which reads and changes the output configuration. KWin scripting
+// only sees the enabled outputs and can't change the configuration, so the output management goes through it
+const kscreenDoctor = "/usr/bin/kscreen-doctor"
+
+// kscreenOutput is the part of an output, as printed by kscreen-doctor -j, which is used by this package
+type kscreenOutput struct {
+	Name      string  `json:"name"`
+	Connected bool    `json:"connected"`
+	Enabled   bool    `json:"enabled"`
+	Priority  int     `json:"priority"`
+	Scale     float64 `json:"scale"`
+	Pos       Point   `json:"pos"`
+	Size      struct {
+		Width  int `json:"width"`
+		Height int `json:"height"`
+	} `json:"size"`
+}
+
+// getKScreenOutputs returns all outputs KScreen knows about, connected or not
+func (k KWin) getKScreenOutputs() ([]kscreenOutput, error) {
+	output, err := k.callProgramAndReadStdout(context.Background(), kscreenDoctor, "-j")
+	if err != nil {
+		fmt.Printf("Error running kscreen-doctor: %v\n", err)
+		return nil, err
+	}
+	config := struct {
+		Outputs []kscreenOutput `json:"outputs"`
+	}{}
+	if err := json.Unmarshal(output, &config); err != nil {
+		return nil, fmt.Errorf("invalid kscreen-doctor output: %w", err)
+	}
+	return config.Outputs, nil
+}
+
+// GetAllOutputs returns a map of all connected Screen outputs, including the disabled ones, where the map key is the
+// Screen name. The enabled outputs are reported like in GetScreens. The disabled ones are read from KScreen, have
+// Enabled set to false, an Index of -1 and, as they are not part of the compositor space, only their configured
+// geometry. Primary is set for the primary output
+func (k KWin) GetAllOutputs() (map[string]Screen, error) {
+	screens, err := k.GetScreens()
+	if err != nil {
+		fmt.Printf("Error getting screens: %v\n", err)
+		return nil, err
+	}
+	outputs, err := k.getKScreenOutputs()
+	if err != nil {
+		return nil, err
+	}
+	for _, o := range outputs {
+		if !o.Connected {
+			continue
+		}
+		if s, ok := screens[o.Name]; ok {
+			s.Primary = o.Priority == 1
+			screens[o.Name] = s
+			continue
+		}
+		scale := o.Scale
+		if scale <= 0 {
+			scale = 1
+		}
+		screens[o.Name] = Screen{
+			Name:  o.Name,
+			Index: -1,
+			Geometry: Rect{
+				TopLeft: o.Pos,
+				BottomRight: Point{
+					X: o.Pos.X + int(math.Round(float64(o.Size.Width)/scale)),
+					Y: o.Pos.Y + int(math.Round(float64(o.Size.Height)/scale)),
+				},
+			},
+			PixelRatio: scale,
+			Enabled:    false,
+			Primary:    o.Priority == 1,
+		}
+	}
+	return screens, nil
+}
+
+// SetPrimaryScreen will attempt to make a given Screen output the primary one, through KScreen, as KWin scripting can't
+// change the output configuration. The other outputs keep their relative order
+func (k KWin) SetPrimaryScreen(s Screen) error {
+	if s.Name == "" {
+		return fmt.Errorf("screen has no name")
+	}
+	_, err := k.callProgramAndReadOutput(context.Background(), kscreenDoctor, fmt.Sprintf("output.%s.priority.1", s.Name))
+	if err != nil {
+		fmt.Printf("Error setting primary screen: %v\n", err)
+		return err
+	}
+	return nil
+}
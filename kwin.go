@@ -4,12 +4,15 @@ package go_kwin6
 import (
 	"bufio"
 	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
 	"os/exec"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/google/uuid"
@@ -23,13 +26,92 @@ KWin scripts are saved in temp folder as files, then loaded in KWin scripting ma
 */
 
 const (
-	dbusSend   = "/usr/bin/dbus-send"
-	journalCtl = "/usr/bin/journalctl"
+	dbusSend    = "/usr/bin/dbus-send"
+	dbusMonitor = "/usr/bin/dbus-monitor"
+	journalCtl  = "/usr/bin/journalctl"
+
+	journalTimeFormat = "2006-01-02 15:04:05.000000"
+
+	// unsupportedMarker is printed by the scripts which find that the running KWin version lacks the needed API
+	unsupportedMarker = "#unsupported"
+	// notFoundMarker is printed by the scripts which don't find the object they were asked to act on
+	notFoundMarker = "#notfound"
 )
 
+// ErrNoScriptOutput is returned (wrapped) by the methods which ran a script that must report something, e.g. there is
+// always at least one Screen, but no output was captured. It usually means the logging rules for the KWin scripting
+// output are not active, see Validate
+var ErrNoScriptOutput = errors.New("no script output captured")
+
 type (
 	// KWin is a common methods receiver to act like an object
-	KWin struct{}
+	KWin struct {
+		// OutputMode selects how the output of the executed scripts is captured, defaults to OutputJournal
+		OutputMode OutputMode
+		// PrintFunction selects the JavaScript function the scripts print their output with, defaults to PrintBuiltin,
+		// see DetectPrintFunction
+		PrintFunction PrintFunction
+		// AutoFlushInterval, when set, makes the first script queued by a Queued KWin schedule a Flush after the interval
+		AutoFlushInterval time.Duration
+		// FollowJournal makes the script output be read by following the journal from before the script is run until
+		// the end marker printed by the script shows up, instead of querying the journal for the time window the script
+		// was running in, which is prone to missing output that lands just outside the window
+		FollowJournal bool
+		// JournalDeadline is the maximum time to wait for the end marker when FollowJournal is set, defaults to
+		// defaultJournalDeadline
+		JournalDeadline time.Duration
+		// JournalAllBoots makes the journal queries search the logs of all boots, by default they are scoped to the
+		// current boot (--boot), as script output can't be found in the logs of the previous ones anyway
+		JournalAllBoots bool
+		// JournalUserUnit, when set, scopes the journal queries to the given systemd user unit KWin runs in, e.g.
+		// "plasma-kwin_wayland.service", which narrows the search on machines with a large journal
+		JournalUserUnit string
+		// ReplyTimeout, when set, is passed to dbus-send as --reply-timeout on every call which waits for a reply, so a
+		// hung KWin fails the call after this time instead of dbus's default of 25 seconds
+		ReplyTimeout time.Duration
+		// OnScriptTimings, when set, is called after every script run which reads its output from the journal time
+		// window, with the time each step took, to help diagnose journal lag
+		OnScriptTimings func(ScriptTimings)
+		// KeepScriptFiles leaves the generated script files in the temp folder after they are run and prints their path,
+		// so the exact script which was loaded can be inspected
+		KeepScriptFiles bool
+		// NoProcLookup disables all reads of /proc, for sandboxes where it is masked. The windows then have no CmdLine
+		// and their AppName is the resource class, and the process tree matching is not available
+		NoProcLookup bool
+		// Clock returns the current time used for the journal time windows, defaults to time.Now. It is meant for
+		// tests, which need to control the time window deterministically
+		Clock func() time.Time
+
+		queued bool
+		state  *kwinState
+	}
+	// ScriptTimings holds the time each step of a script run took
+	ScriptTimings struct {
+		Load    time.Duration
+		Run     time.Duration
+		Stop    time.Duration
+		Journal time.Duration
+		// Lines is the number of output lines read from the journal
+		Lines int
+	}
+	// kwinState holds the mutable state of a KWin instance, which is shared by all of its copies
+	kwinState struct {
+		mu         sync.Mutex
+		queue      []string
+		flushTimer *time.Timer
+
+		lastWindows     map[uuid.UUID]Window
+		lastWindowsTime time.Time
+		lastGeometries  map[uuid.UUID]Rect
+
+		lastFailedScript string
+
+		closed        bool
+		loadedScripts map[int]bool
+		watches       sync.WaitGroup
+		watchCancels  map[int]context.CancelFunc
+		nextWatch     int
+	}
 	// Point is a struct that contains integer valued coordinates for screen geometry
 	Point struct {
 		X int `json:"x"`
@@ -44,42 +126,72 @@ type (
 	//physical screen/monitor
 	Screen struct {
 		Name         string  `json:"name"`
+		Index        int     `json:"index"`
 		Geometry     Rect    `json:"geometry"`
 		Manufacturer string  `json:"manufacturer"`
 		Model        string  `json:"model"`
 		SerialNumber string  `json:"serial"`
 		PixelRatio   float64 `json:"pixelRatio"`
+		// Enabled is false for the connected but disabled outputs, which are only reported by GetAllOutputs
+		Enabled bool `json:"enabled"`
+		// Primary is true for the primary output. KWin scripting doesn't know it, so it is only populated by
+		// GetAllOutputs
+		Primary bool `json:"primary"`
 	}
 	// Desktop is a struct that contains the main properties of KWin::VirtualDesktop object which represents a virtual
-	//desktop containing client program windows
+	//desktop containing client program windows. The id, name, x11 number and position are all KWin scripting can read
+	//about a desktop, there are no custom properties. Per desktop wallpapers and such belong to the Plasma shell, not
+	//to KWin, so they are not available here
 	Desktop struct {
 		Id        string `json:"id"`
 		Index     int    `json:"index"`
 		Name      string `json:"name"`
 		X11Number int    `json:"x11Number"`
+		// WindowCount is the number of windows on the desktop, including the ones on all desktops. It is only
+		// populated by GetEnvironment
+		WindowCount int `json:"windowCount"`
 	}
 	// Window is a struct that contains the most useful properties of KWin::Window object which represents a client
-	//program window
+	//program window. The X, Y, Width and Height are those of the frame, i.e. including the decoration, same as the
+	//FrameGeometry. The ClientGeometry covers the window contents only. The WindowType is the NET::WindowType name in
+	//lower camel case (normal, dialog, utility, toolbar, menu, dock, desktop, splash, popupMenu etc.), "unknown" when
+	//KWin reports none of them
 	Window struct {
-		Id               string      `json:"id"`
-		Caption          string      `json:"caption"`
-		Pid              int         `json:"pid"`
-		CmdLine          string      `json:"cmdline"`
-		AppName          string      `json:"appname"`
-		ResourceClass    string      `json:"resourceClass"`
-		ResourceName     string      `json:"resourceName"`
-		X                float64     `json:"x"`
-		Y                float64     `json:"y"`
-		Width            float64     `json:"width"`
-		Height           float64     `json:"height"`
-		Fullscreen       bool        `json:"fullscreen"`
-		OnAllDesktops    bool        `json:"onAllDesktops"`
-		KeepAbove        bool        `json:"keepAbove"`
-		KeepBelow        bool        `json:"keepBelow"`
-		Minimized        bool        `json:"minimized"`
-		DesktopIds       []uuid.UUID `json:"desktopIds"`
-		Desktops         []Desktop   `json:"desktops"`
-		DemandsAttention bool        `json:"demandsAttention"`
+		Id                    string      `json:"id"`
+		Caption               string      `json:"caption"`
+		Pid                   int         `json:"pid"`
+		CmdLine               string      `json:"cmdline"`
+		AppName               string      `json:"appname"`
+		ResourceClass         string      `json:"resourceClass"`
+		ResourceName          string      `json:"resourceName"`
+		X                     float64     `json:"x"`
+		Y                     float64     `json:"y"`
+		Width                 float64     `json:"width"`
+		Height                float64     `json:"height"`
+		MinWidth              float64     `json:"minWidth"`
+		MinHeight             float64     `json:"minHeight"`
+		MaxWidth              float64     `json:"maxWidth"`
+		MaxHeight             float64     `json:"maxHeight"`
+		Fullscreen            bool        `json:"fullscreen"`
+		OnAllDesktops         bool        `json:"onAllDesktops"`
+		KeepAbove             bool        `json:"keepAbove"`
+		KeepBelow             bool        `json:"keepBelow"`
+		Minimized             bool        `json:"minimized"`
+		SpecialWindow         bool        `json:"specialWindow"`
+		MaximizedHorizontally bool        `json:"maximizedHorizontally"`
+		MaximizedVertically   bool        `json:"maximizedVertically"`
+		DesktopIds            []uuid.UUID `json:"desktopIds"`
+		Desktops              []Desktop   `json:"desktops"`
+		DemandsAttention      bool        `json:"demandsAttention"`
+		Activities            []string    `json:"activities"`
+		StackingOrder         int         `json:"stackingOrder"`
+		RestoreGeometry       Rect        `json:"restoreGeometry"`
+		TransientForId        *uuid.UUID  `json:"transientForId"`
+		NoBorder              bool        `json:"noBorder"`
+		FrameGeometry         Rect        `json:"frameGeometry"`
+		ClientGeometry        Rect        `json:"clientGeometry"`
+		WindowType            string      `json:"windowType"`
+		WindowRole            string      `json:"windowRole"`
 	}
 	// Environment is a struct that contains all detected Screen, virtual Desktop and Window objects on the system
 	Environment struct {
@@ -89,18 +201,109 @@ type (
 		Desktops map[uuid.UUID]Desktop `json:"desktops"`
 		// Windows is a map of Window objects, where the key is the Window uuid
 		Windows map[uuid.UUID]Window `json:"windows"`
+		// ActiveWindowId is the uuid of the Window which has the focus, uuid.Nil when no window is active
+		ActiveWindowId uuid.UUID `json:"activeWindowId"`
+		// CurrentDesktopId is the uuid of the currently active Desktop
+		CurrentDesktopId uuid.UUID `json:"currentDesktopId"`
+		// ActiveScreen is the name of the currently active Screen
+		ActiveScreen string `json:"activeScreen"`
 	}
 )
 
 // NewKWin is a helper method which creates new instance of the KWin struct
 func NewKWin() KWin {
-	return KWin{}
+	return KWin{state: &kwinState{
+		loadedScripts:  make(map[int]bool),
+		lastGeometries: make(map[uuid.UUID]Rect),
+		watchCancels:   make(map[int]context.CancelFunc),
+	}}
+}
+
+// Close releases the resources held by the KWin object: it flushes the queued scripts, stops all running watch scripts
+// (closing their channels), stops and deregisters any script this instance loaded but didn't stop yet, and removes the
+// script temp files. Watches can't be started after Close, but the other methods keep working. Close is idempotent
+func (k KWin) Close() error {
+	if k.state == nil {
+		return nil
+	}
+	k.state.mu.Lock()
+	if k.state.closed {
+		k.state.mu.Unlock()
+		return nil
+	}
+	k.state.closed = true
+	cancels := make([]context.CancelFunc, 0, len(k.state.watchCancels))
+	for _, cancel := range k.state.watchCancels {
+		cancels = append(cancels, cancel)
+	}
+	k.state.mu.Unlock()
+
+	errs := make([]error, 0)
+	if err := k.Flush(); err != nil {
+		errs = append(errs, err)
+	}
+	for _, cancel := range cancels {
+		cancel()
+	}
+	k.state.watches.Wait()
+
+	k.state.mu.Lock()
+	scripts := make([]int, 0, len(k.state.loadedScripts))
+	for scriptNo := range k.state.loadedScripts {
+		scripts = append(scripts, scriptNo)
+	}
+	k.state.mu.Unlock()
+	for _, scriptNo := range scripts {
+		if err := k.stopScript(context.Background(), scriptNo); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// trackScript records whether the script with the given registration number is loaded in KWin, so Close can stop the
+// scripts which were left behind
+func (k KWin) trackScript(scriptNo int, loaded bool) {
+	if k.state == nil {
+		return
+	}
+	k.state.mu.Lock()
+	defer k.state.mu.Unlock()
+	if loaded {
+		k.state.loadedScripts[scriptNo] = true
+	} else {
+		delete(k.state.loadedScripts, scriptNo)
+	}
+}
+
+// trackWatch registers a running watch, so Close can cancel it and wait for its cleanup. The returned release function
+// must be called once the watch has finished its cleanup. It fails when the KWin object was closed already
+func (k KWin) trackWatch(cancel context.CancelFunc) (func(), error) {
+	if k.state == nil {
+		return func() {}, nil
+	}
+	k.state.mu.Lock()
+	defer k.state.mu.Unlock()
+	if k.state.closed {
+		return nil, fmt.Errorf("KWin object is closed")
+	}
+	id := k.state.nextWatch
+	k.state.nextWatch++
+	k.state.watchCancels[id] = cancel
+	k.state.watches.Add(1)
+	return func() {
+		k.state.mu.Lock()
+		delete(k.state.watchCancels, id)
+		k.state.mu.Unlock()
+		k.state.watches.Done()
+	}, nil
 }
 
 // callProgramAndReadOutput - starts a process for a given command and arguments, waits for it to finish and reads the
-// process output
-func (k KWin) callProgramAndReadOutput(command string, args ...string) ([]string, error) {
-	cmd := exec.Command(command, args...)
+// process output. The process is killed when ctx is done before it finishes. When the process fails, the output read so
+// far is returned along with the error
+func (k KWin) callProgramAndReadOutput(ctx context.Context, command string, args ...string) ([]string, error) {
+	cmd := exec.CommandContext(ctx, command, args...)
 	if cmd.Err != nil {
 		return nil, cmd.Err
 	}
@@ -129,25 +332,95 @@ func (k KWin) callProgramAndReadOutput(command string, args ...string) ([]string
 		for i := range processOutput {
 			fmt.Printf("%s\n", processOutput[i])
 		}
-		return nil, err
+		return processOutput, err
 	}
 
 	return processOutput, nil
 }
 
+// callProgramAndReadStdout starts a process for a given command and arguments like callProgramAndReadOutput, but only
+// returns its standard output, for the programs whose output is parsed as a whole (e.g. JSON) and which may log
+// warnings on the standard error. The standard error is only printed when the process fails
+func (k KWin) callProgramAndReadStdout(ctx context.Context, command string, args ...string) ([]byte, error) {
+	cmd := exec.CommandContext(ctx, command, args...)
+	var errout bytes.Buffer
+	cmd.Stderr = &errout
+	output, err := cmd.Output()
+	if err != nil {
+		fmt.Printf("Command finished with error: %v\n", err)
+		fmt.Print(errout.String())
+		return output, err
+	}
+	return output, nil
+}
+
 // callDbusSend is a helper function which calls dbus-send command with the given parameters and returns the process
-// output
-func (k KWin) callDbusSend(args ...string) ([]string, error) {
-	return k.callProgramAndReadOutput(dbusSend, args...)
+// output. An error reply, which dbus-send prints as an "Error <name>: <message>" line, is returned as an error
+func (k KWin) callDbusSend(ctx context.Context, args ...string) ([]string, error) {
+	if k.ReplyTimeout > 0 {
+		for i := range args {
+			if args[i] == "--print-reply" {
+				timeout := fmt.Sprintf("--reply-timeout=%d", k.ReplyTimeout.Milliseconds())
+				args = append(args[:i+1:i+1], append([]string{timeout}, args[i+1:]...)...)
+				break
+			}
+		}
+	}
+	output, err := k.callProgramAndReadOutput(ctx, dbusSend, args...)
+	for _, s := range output {
+		if !strings.HasPrefix(s, "Error ") {
+			continue
+		}
+		if err == nil {
+			err = fmt.Errorf("dbus-send reported an error")
+		}
+		if strings.Contains(s, "org.freedesktop.DBus.Error.NoReply") {
+			return nil, fmt.Errorf("no dbus reply within %v: %w", k.ReplyTimeout, err)
+		}
+		return nil, fmt.Errorf("dbus call failed: %s: %w", strings.TrimPrefix(s, "Error "), err)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return output, nil
+}
+
+// checkVoidReply verifies that the output of a dbus-send --print-reply call of a method which returns nothing holds the
+// method return
+func checkVoidReply(output []string, method string) error {
+	for _, s := range output {
+		if strings.HasPrefix(s, "method return") {
+			return nil
+		}
+	}
+	return fmt.Errorf("no reply to %s: %s", method, output)
 }
 
 // loadScript calls KWin scripting infrastructure to load a file which contains a JavaScript scriptlet and returns the
 // script registration number inside KWin, with which it can be later invoked/stopped
-func (k KWin) loadScript(scriptPath string) (int, error) {
-	output, err := k.callDbusSend(
+func (k KWin) loadScript(ctx context.Context, scriptPath string) (int, error) {
+	return k.loadNamedScript(ctx, scriptPath, "")
+}
+
+// loadNamedScript works like loadScript, but registers the script under the given plugin name, unless it is empty, so
+// it can be found and unloaded by the name later
+func (k KWin) loadNamedScript(ctx context.Context, scriptPath, pluginName string) (int, error) {
+	info, err := os.Stat(scriptPath)
+	if err != nil {
+		return -1, fmt.Errorf("script file is not accessible: %w", err)
+	}
+	if info.Mode().Perm()&0o004 == 0 {
+		// KWin may run as another user, or the umask may have stripped the permission chmod tried to set
+		return -1, fmt.Errorf("script file %s is not readable by other users (mode %v)", scriptPath, info.Mode().Perm())
+	}
+	args := []string{
 		"--print-reply",
 		"--dest=org.kde.KWin",
-		"/Scripting", "org.kde.kwin.Scripting.loadScript", "string:"+scriptPath)
+		"/Scripting", "org.kde.kwin.Scripting.loadScript", "string:" + scriptPath}
+	if pluginName != "" {
+		args = append(args, "string:"+pluginName)
+	}
+	output, err := k.callDbusSend(ctx, args...)
 	if err != nil {
 		return -1, err
 	}
@@ -163,13 +436,17 @@ func (k KWin) loadScript(scriptPath string) (int, error) {
 	if err != nil {
 		return -1, err
 	}
+	if iRegNo < 0 {
+		return -1, fmt.Errorf("KWin could not read script file %s", scriptPath)
+	}
+	k.trackScript(iRegNo, true)
 	return iRegNo, nil
 }
 
 // runScript calls KWin scripting infrastructure to execute a previously loaded JavaScript scriptlet. It returns error
 // on failure, the actual script generated output is gathered by journalctl
-func (k KWin) runScript(scriptNo int) error {
-	_, err := k.callDbusSend(
+func (k KWin) runScript(ctx context.Context, scriptNo int) error {
+	output, err := k.callDbusSend(ctx,
 		"--print-reply",
 		"--dest=org.kde.KWin",
 		fmt.Sprintf("/Scripting/Script%d", scriptNo), "org.kde.kwin.Script.run")
@@ -177,99 +454,177 @@ func (k KWin) runScript(scriptNo int) error {
 	if err != nil {
 		return err
 	}
-	return nil
+	return checkVoidReply(output, fmt.Sprintf("run of script %d", scriptNo))
 }
 
 // stopScript calls KWin scripting infrastructure to stop and deregister a previously loaded JavaScript scriptlet.
-// It returns error on failure
-func (k KWin) stopScript(scriptNo int) error {
-	_, err := k.callDbusSend("--print-reply", "--dest=org.kde.KWin", fmt.Sprintf("/Scripting/Script%d", scriptNo), "org.kde.kwin.Script.stop")
+// It returns error on failure. It is called with a context which is not done yet even when the operation was cancelled,
+// as a script left loaded in KWin would leak its registration
+func (k KWin) stopScript(ctx context.Context, scriptNo int) error {
+	output, err := k.callDbusSend(ctx, "--print-reply", "--dest=org.kde.KWin", fmt.Sprintf("/Scripting/Script%d", scriptNo), "org.kde.kwin.Script.stop")
+	k.trackScript(scriptNo, false)
 
 	if err != nil {
 		return err
 	}
-	return nil
+	return checkVoidReply(output, fmt.Sprintf("stop of script %d", scriptNo))
+}
+
+// now returns the current time of the Clock of the KWin object
+func (k KWin) now() time.Time {
+	if k.Clock != nil {
+		return k.Clock()
+	}
+	return time.Now()
+}
+
+// journalArgs returns the journalctl arguments which select the script output, scoped as configured in the KWin
+// object, followed by the given arguments
+func (k KWin) journalArgs(args ...string) []string {
+	journalArgs := []string{"QT_CATEGORY=js", "QT_CATEGORY=kwin_scripting", "-o", "cat"}
+	if !k.JournalAllBoots {
+		journalArgs = append(journalArgs, "--boot")
+	}
+	if k.JournalUserUnit != "" {
+		journalArgs = append(journalArgs, "--user-unit", k.JournalUserUnit)
+	}
+	return append(journalArgs, args...)
+}
+
+// journalWindowArgs returns the journalctl arguments which select the script output found between the two timestamps
+func (k KWin) journalWindowArgs(from, to time.Time) []string {
+	return k.journalArgs(
+		"--since", from.Format(journalTimeFormat),
+		"--until", to.Format(journalTimeFormat),
+		"--no-pager",
+		"--quiet")
 }
 
 // getJournal executes the journalctl to gather the previously executed script output, found between the two timestamps
-// and filtered by the QT_ flags below
-func (k KWin) getJournal(from, to time.Time) ([]string, error) {
-	format := "2006-01-02 15:04:05.000000"
-	since := from.Format(format)
-	until := to.Format(format)
-	output, err := k.callProgramAndReadOutput(
-		journalCtl,
-		"QT_CATEGORY=js", "QT_CATEGORY=kwin_scripting",
-		"-o", "cat",
-		"--since", since,
-		"--until", until,
-		"--no-pager")
+// and filtered by the QT_ flags in journalArgs
+func (k KWin) getJournal(ctx context.Context, from, to time.Time) ([]string, error) {
+	output, err := k.callProgramAndReadOutput(ctx, journalCtl, k.journalWindowArgs(from, to)...)
 	if err != nil {
 		return nil, err
 	}
 	return output, nil
 }
 
-// loadExecuteAndGetOutput executes given JavaScript code by
-//
-//	Saving into a temporary file
-//	Loading/Registering it with KWin scripting infrastructure
-//	Running the script
-//	Stopping the script
-//	Gathering the script output from the journal for the time window the script was running
-func (k KWin) loadExecuteAndGetOutput(script string) ([]string, error) {
+// createScriptFile saves the given JavaScript code into a temporary file, readable by KWin
+func (k KWin) createScriptFile(script string) (*os.File, error) {
 	scriptFile, err := os.CreateTemp(os.TempDir(), "kwin_script_*.js")
 	if err != nil {
 		return nil, err
 	}
-	defer func() {
-		err := scriptFile.Close()
-		if err != nil {
-			fmt.Printf("Error closing script file: %v\n", err)
-			return
-		}
-		err = os.Remove(scriptFile.Name())
-		if err != nil {
-			fmt.Printf("Error removing script file: %v\n", err)
-			return
-		}
-	}()
-	_, err = scriptFile.WriteString(script)
+	_, err = scriptFile.WriteString(k.PrintFunction.shim() + script)
 	if err != nil {
 		fmt.Printf("Error writing script file: %v\n", err)
+		k.removeScriptFile(scriptFile)
 		return nil, err
 	}
 	err = os.Chmod(scriptFile.Name(), 0777) //KWin needs to be able to read the script, 777 may be a bit excessive
 	if err != nil {
 		fmt.Printf("Error chmod: %v\n", err)
+		k.removeScriptFile(scriptFile)
+		return nil, err
+	}
+	return scriptFile, nil
+}
+
+// removeScriptFile closes and deletes a script file previously created by createScriptFile. With KeepScriptFiles set,
+// the file is only closed and its path printed
+func (k KWin) removeScriptFile(scriptFile *os.File) {
+	err := scriptFile.Close()
+	if err != nil {
+		fmt.Printf("Error closing script file: %v\n", err)
+		return
+	}
+	if k.KeepScriptFiles {
+		fmt.Printf("Keeping script file: %s\n", scriptFile.Name())
+		return
+	}
+	err = os.Remove(scriptFile.Name())
+	if err != nil {
+		fmt.Printf("Error removing script file: %v\n", err)
+		return
+	}
+}
+
+// loadExecuteAndGetOutput executes given JavaScript code by
+//
+//	Saving into a temporary file
+//	Loading/Registering it with KWin scripting infrastructure
+//	Running the script
+//	Stopping the script
+//	Gathering the script output from the journal for the time window the script was running
+func (k KWin) loadExecuteAndGetOutput(script string) ([]string, error) {
+	return k.loadExecuteAndGetOutputContext(context.Background(), script)
+}
+
+// loadExecuteAndGetOutputContext is the context aware version of loadExecuteAndGetOutput. When ctx is done the running
+// step is interrupted, but a script which was already loaded is still stopped and deregistered. A script which fails is
+// kept for LastScript and ReRunLast
+func (k KWin) loadExecuteAndGetOutputContext(ctx context.Context, script string) ([]string, error) {
+	output, err := k.executeScript(ctx, script)
+	if err != nil && k.state != nil {
+		k.state.mu.Lock()
+		k.state.lastFailedScript = script
+		k.state.mu.Unlock()
+	}
+	return output, err
+}
+
+// executeScript implements loadExecuteAndGetOutputContext, dispatching on the output mode
+func (k KWin) executeScript(ctx context.Context, script string) ([]string, error) {
+	if k.OutputMode == OutputDBus {
+		return k.loadExecuteAndGetDBusOutput(ctx, script)
+	}
+	if k.FollowJournal {
+		return k.loadExecuteAndFollowJournal(ctx, script)
+	}
+	scriptFile, err := k.createScriptFile(script)
+	if err != nil {
 		return nil, err
 	}
+	defer k.removeScriptFile(scriptFile)
 
-	scriptNo, err := k.loadScript(scriptFile.Name())
+	loadTime := k.now()
+	scriptNo, err := k.loadScript(ctx, scriptFile.Name())
 	if err != nil {
 		fmt.Printf("Error loading script: %v\n", err)
 		return nil, err
 	}
 
-	startTime := time.Now()
-	err = k.runScript(scriptNo)
+	startTime := k.now()
+	err = k.runScript(ctx, scriptNo)
 	if err != nil {
 		fmt.Printf("Error running script: %v\n", err)
+		_ = k.stopScript(context.Background(), scriptNo)
 		return nil, err
 	}
 
-	err = k.stopScript(scriptNo)
-	endTime := time.Now()
+	stopTime := k.now()
+	err = k.stopScript(context.Background(), scriptNo)
+	endTime := k.now()
 	if err != nil {
 		fmt.Printf("Error stopping script: %v\n", err)
 		return nil, err
 	}
 
-	journalOutput, err := k.getJournal(startTime, endTime)
+	journalOutput, err := k.getJournal(ctx, startTime, endTime)
 	if err != nil {
 		fmt.Printf("Error getting journal output: %v\n", err)
 		return nil, err
 	}
+	if k.OnScriptTimings != nil {
+		k.OnScriptTimings(ScriptTimings{
+			Load:    startTime.Sub(loadTime),
+			Run:     stopTime.Sub(startTime),
+			Stop:    endTime.Sub(stopTime),
+			Journal: k.now().Sub(endTime),
+			Lines:   len(journalOutput),
+		})
+	}
 	return journalOutput, nil
 }
 
@@ -285,17 +640,25 @@ func (k KWin) getProcessCmdLine(processId int) (string, error) {
 	return strings.TrimSpace(string(cmdLine)), nil
 }
 
-// GetScreens returns a map of detected Screen objects where the map key is the Screen name
-func (k KWin) GetScreens() (map[string]Screen, error) {
-	script := `
-	for (var i = 0; i< workspace.screens.length; i++) {
+// escapeJSString escapes the given string, so it can be safely embedded between double quotes in a JavaScript scriptlet
+func escapeJSString(s string) string {
+	b, _ := json.Marshal(s)
+	return string(b[1 : len(b)-1])
+}
+
+// screenToJSON is a JavaScript function, shared by the scripts which report screens, that serializes the Output at the
+// given index of workspace.screens into the JSON representation of the Screen struct
+const screenToJSON = `
+	function screenToJSON(i) {
 		var screen = workspace.screens[i]
 		var out = "{"
-		out += "\"name\": \""+screen.name+"\","
-		out += "\"manufacturer\": \""+screen.manufacturer+"\","
-		out += "\"model\": \""+screen.model+"\","
-		out += "\"serial\": \""+screen.serialNumber+"\","
-		out += "\"pixelRatio\": "+screen.devicePixelRatio+","
+		out += "\"name\": "+JSON.stringify(screen.name || "")+","
+		out += "\"index\": "+i+","
+		out += "\"manufacturer\": "+JSON.stringify(screen.manufacturer || "")+","
+		out += "\"model\": "+JSON.stringify(screen.model || "")+","
+		out += "\"serial\": "+JSON.stringify(screen.serialNumber || "")+","
+		out += "\"pixelRatio\": "+(screen.devicePixelRatio || 0)+","
+		out += "\"enabled\": true,"
 		out += "\"geometry\": {"
 		out += "\"topLeft\": {"
 		out += "\"x\":"+screen.geometry.left+","
@@ -307,19 +670,30 @@ func (k KWin) GetScreens() (map[string]Screen, error) {
 		out += "}"
 		out += "}"
 		out += "}"
-		print(out)
+		return out
+	}`
+
+// GetScreens returns a map of detected Screen objects where the map key is the Screen name
+func (k KWin) GetScreens() (map[string]Screen, error) {
+	script := screenToJSON + `
+	for (var i = 0; i < workspace.screens.length; i++) {
+		print(screenToJSON(i))
 	}`
 	output, err := k.loadExecuteAndGetOutput(script)
 	if err != nil {
 		fmt.Printf("Error running script for screens list: %v\n", err)
 		return nil, err
 	}
+	if len(output) == 0 {
+		return nil, fmt.Errorf("no screens reported: %w", ErrNoScriptOutput)
+	}
+	if err := scriptError(output); err != nil {
+		return nil, err
+	}
 	outputMap := make(map[string]Screen)
 	for _, s := range output {
-		d := Screen{}
-		s = strings.ReplaceAll(s, "js: ", "")
-		s = strings.ReplaceAll(s, "undefined", "0")
-		if err := json.Unmarshal([]byte(s), &d); err != nil {
+		d, err := parseScreenLine(s)
+		if err != nil {
 			return nil, err
 		}
 		outputMap[d.Name] = d
@@ -327,29 +701,41 @@ func (k KWin) GetScreens() (map[string]Screen, error) {
 	return outputMap, nil
 }
 
-// GetDesktops returns a map of detected Desktop objects where the map key is the Desktop ID
-func (k KWin) GetDesktops() (map[uuid.UUID]Desktop, error) {
-	script := `
-	for (var i = 0; i < workspace.desktops.length; i++) {
+// desktopToJSON is a JavaScript function, shared by the scripts which report desktops, that serializes the
+// VirtualDesktop at the given index of workspace.desktops into the JSON representation of the Desktop struct
+const desktopToJSON = `
+	function desktopToJSON(i) {
 		var desktop = workspace.desktops[i]
 		var out = "{"
 		out += "\"id\": \""+desktop.id+"\","
 		out += "\"index\": "+i+","
-		out += "\"name\": \""+desktop.name+"\","
+		out += "\"name\": "+JSON.stringify(desktop.name)+","
 		out += "\"x11Number\": "+desktop.x11DesktopNumber
 		out += "}"
-		print(out)
+		return out
+	}`
+
+// GetDesktops returns a map of detected Desktop objects where the map key is the Desktop ID
+func (k KWin) GetDesktops() (map[uuid.UUID]Desktop, error) {
+	script := desktopToJSON + `
+	for (var i = 0; i < workspace.desktops.length; i++) {
+		print(desktopToJSON(i))
 	}`
 	output, err := k.loadExecuteAndGetOutput(script)
 	if err != nil {
 		fmt.Printf("Error running script for desktops list: %v\n", err)
 		return nil, err
 	}
+	if len(output) == 0 {
+		return nil, fmt.Errorf("no desktops reported: %w", ErrNoScriptOutput)
+	}
+	if err := scriptError(output); err != nil {
+		return nil, err
+	}
 	outputMap := make(map[uuid.UUID]Desktop)
 	for _, s := range output {
-		d := Desktop{}
-		ss := strings.ReplaceAll(s, "js: ", "")
-		if err := json.Unmarshal([]byte(ss), &d); err != nil {
+		d, err := parseDesktopLine(s)
+		if err != nil {
 			return nil, err
 		}
 		outputMap[uuid.MustParse(d.Id)] = d
@@ -357,72 +743,278 @@ func (k KWin) GetDesktops() (map[uuid.UUID]Desktop, error) {
 	return outputMap, nil
 }
 
-// GetWindows returns a map of detected Window objects where the map key is the Window ID
-func (k KWin) GetWindows(desktops map[uuid.UUID]Desktop) (map[uuid.UUID]Window, error) {
-	script := `
-	for (const window of workspace.windowList()) {
-		if (window.specialWindow) {
-			continue;
+// GetCurrentDesktop returns the currently active Desktop
+func (k KWin) GetCurrentDesktop() (Desktop, error) {
+	script := desktopToJSON + `
+	var desktop = workspace.currentDesktop
+	for (var i = 0; i < workspace.desktops.length; i++) {
+		if (workspace.desktops[i].id === desktop.id) {
+			print(desktopToJSON(i))
+			break;
+		}
+	}`
+	output, err := k.loadExecuteAndGetOutput(script)
+	if err != nil {
+		fmt.Printf("Error running script for current desktop: %v\n", err)
+		return Desktop{}, err
+	}
+	if len(output) == 0 {
+		return Desktop{}, fmt.Errorf("current desktop not found: %w", ErrNoScriptOutput)
+	}
+	if err := scriptError(output); err != nil {
+		return Desktop{}, err
+	}
+	return parseDesktopLine(output[0])
+}
+
+// windowMaximizeMode is a JavaScript function that returns the maximize mode of a KWin::Window as a bit mask, where 1 is
+// vertical and 2 horizontal maximization. KWin versions which don't expose the maximize mode to scripts have it derived
+// from the window geometry filling the maximize area
+const windowMaximizeMode = `
+	function windowMaximizeMode(window) {
+		var maximizeMode = window.maximizeMode;
+		if (maximizeMode === undefined) {
+			var area = workspace.clientArea(KWin.MaximizeArea, window);
+			maximizeMode = 0;
+			if (window.y === area.y && window.height === area.height) {
+				maximizeMode |= 1;
+			}
+			if (window.x === area.x && window.width === area.width) {
+				maximizeMode |= 2;
+			}
+		}
+		return maximizeMode;
+	}`
+
+// windowJSONHelpers are the JavaScript functions windowToJSON and GetWindowsFields use to serialize the parts of a
+// KWin::Window which need more than reading a property: the maximize mode, the window type and the geometry rectangles
+const windowJSONHelpers = windowMaximizeMode + `
+	function windowType(window) {
+		var types = [
+			["desktop", window.desktopWindow], ["dock", window.dock], ["toolbar", window.toolbar],
+			["menu", window.menu], ["utility", window.utility], ["splash", window.splash],
+			["dialog", window.dialog], ["dropdownMenu", window.dropdownMenu], ["popupMenu", window.popupMenu],
+			["tooltip", window.tooltip], ["notification", window.notification],
+			["criticalNotification", window.criticalNotification], ["appletPopup", window.appletPopup],
+			["onScreenDisplay", window.onScreenDisplay], ["comboBox", window.comboBox], ["dndIcon", window.dndIcon],
+			["normal", window.normalWindow]
+		];
+		for (const t of types) {
+			if (t[1] === true) {
+				return t[0];
+			}
 		}
+		return "unknown";
+	}
+
+	function rectToJSON(r) {
+		var out = "{"
+		out += "\"topLeft\": {\"x\": "+Math.round(r.x)+", \"y\": "+Math.round(r.y)+"},"
+		out += "\"bottomRight\": {\"x\": "+Math.round(r.x+r.width)+", \"y\": "+Math.round(r.y+r.height)+"}"
+		out += "}"
+		return out
+	}`
+
+// windowToJSON is a JavaScript function, shared by the scripts which report windows, that serializes a KWin::Window into
+// the JSON representation of the Window struct
+const windowToJSON = windowJSONHelpers + `
+	function windowToJSON(window) {
 		var out = "{"
 		out += "\"id\": \""+window.internalId.toString().replace(/{/, "").replace(/}/, "")+"\","
-		out += "\"caption\": \""+window.caption.replace(/\"/g, "")+"\","
+		out += "\"caption\": "+JSON.stringify(window.caption)+","
 		out += "\"pid\": "+window.pid+","
-		out += "\"resourceName\": \""+window.resourceName+"\","
-		out += "\"resourceClass\": \""+window.resourceClass+"\","
+		out += "\"resourceName\": "+JSON.stringify(window.resourceName)+","
+		out += "\"resourceClass\": "+JSON.stringify(window.resourceClass)+","
 		out += "\"x\": "+window.x+","
 		out += "\"y\": "+window.y+","
 		out += "\"width\": "+window.width+","
 		out += "\"height\": "+window.height+","
+		var minSize = window.minSize || {width: 0, height: 0};
+		var maxSize = window.maxSize || {width: 0, height: 0};
+		out += "\"minWidth\": "+minSize.width+","
+		out += "\"minHeight\": "+minSize.height+","
+		out += "\"maxWidth\": "+maxSize.width+","
+		out += "\"maxHeight\": "+maxSize.height+","
 		out += "\"fullScreen\": "+window.fullScreen+","
 		out += "\"onAllDesktops\": "+window.onAllDesktops+","
 		out += "\"keepAbove\": "+window.keepAbove+","
 		out += "\"keepBelow\": "+window.keepBelow+","
 		out += "\"minimized\": "+window.minimized+","
-    	out += "\"demandsAttention\": "+window.demandsAttention+","
-        out += "\"desktopIds\": ["
-        for (var i = 0; i < window.desktops.length; i++) {
-            d = window.desktops[i];
-            out += "\""+d.id+"\"";
-            if (i < window.desktops.length-1) {
-                out += ","
-            }
-        }
-        out += "]"
+		out += "\"specialWindow\": "+window.specialWindow+","
+		var maximizeMode = windowMaximizeMode(window);
+		out += "\"maximizedVertically\": "+((maximizeMode & 1) !== 0)+","
+		out += "\"maximizedHorizontally\": "+((maximizeMode & 2) !== 0)+","
+		out += "\"demandsAttention\": "+window.demandsAttention+","
+		out += "\"noBorder\": "+(window.noBorder === true)+","
+		out += "\"windowType\": \""+windowType(window)+"\","
+		out += "\"windowRole\": "+JSON.stringify(window.windowRole || "")+","
+		out += "\"activities\": "+JSON.stringify(window.activities || [])+","
+		var stackingOrder = window.stackingOrder;
+		if (stackingOrder === undefined) {
+			stackingOrder = workspace.stackingOrder.indexOf(window);
+		}
+		out += "\"stackingOrder\": "+stackingOrder+","
+		var restore = window.geometryRestore;
+		if (!restore || restore.width <= 0 || restore.height <= 0) {
+			restore = window.frameGeometry;
+		}
+		out += "\"restoreGeometry\": "+rectToJSON(restore)+","
+		out += "\"frameGeometry\": "+rectToJSON(window.frameGeometry)+","
+		out += "\"clientGeometry\": "+rectToJSON(window.clientGeometry || window.frameGeometry)+","
+		var transientFor = window.transientFor;
+		if (transientFor) {
+			out += "\"transientForId\": \""+transientFor.internalId.toString().replace(/{/, "").replace(/}/, "")+"\","
+		} else {
+			out += "\"transientForId\": null,"
+		}
+		out += "\"desktopIds\": ["
+		for (var i = 0; i < window.desktops.length; i++) {
+			var d = window.desktops[i];
+			out += "\""+d.id+"\"";
+			if (i < window.desktops.length-1) {
+				out += ","
+			}
+		}
+		out += "]"
 		out += "}"
-		print(out)
+		return out
+	}`
+
+// GetWindows returns a map of detected Window objects where the map key is the Window ID. Special windows (desktop,
+// panels, notifications etc.) are not included. The windows of all desktops are reported, not only those of the
+// current one, with their actual geometry, as KWin keeps it for windows on other desktops. Only minimized windows may
+// report a stale geometry, see Window.LayoutGeometry
+func (k KWin) GetWindows(desktops map[uuid.UUID]Desktop) (map[uuid.UUID]Window, error) {
+	return k.GetWindowsFiltered(desktops, WindowFilter{})
+}
+
+// GetWindowsOnDesktop returns a map of the Window objects on a given Desktop, including the windows on all desktops,
+// where the map key is the Window ID. The current desktop is not switched, so a layout of any desktop can be captured
+// without disturbing the user
+func (k KWin) GetWindowsOnDesktop(desktops map[uuid.UUID]Desktop, d Desktop) (map[uuid.UUID]Window, error) {
+	id, err := uuid.Parse(d.Id)
+	if err != nil {
+		return nil, fmt.Errorf("invalid desktop id %q: %w", d.Id, err)
+	}
+	return k.GetWindowsFiltered(desktops, WindowFilter{DesktopId: id})
+}
+
+// GetWindowsFiltered returns a map of detected Window objects which satisfy the given filter, where the map key is the
+// Window ID. The filter is evaluated inside the script where possible, so windows which don't match are neither
+// reported nor enriched with process information. The zero value WindowFilter behaves like GetWindows
+func (k KWin) GetWindowsFiltered(desktops map[uuid.UUID]Desktop, filter WindowFilter) (map[uuid.UUID]Window, error) {
+	windows, _, err := k.getWindows(desktops, filter, false)
+	return windows, err
+}
+
+// GetWindowsLenient works like GetWindowsFiltered, except that a window which fails to parse or to be enriched with
+// process information (e.g. because the program exited in the meantime) is skipped instead of failing the whole call.
+// The script output lines of the skipped windows are returned along with the windows which were read fine. Output which
+// is not JSON at all means the script threw, which still fails the call
+func (k KWin) GetWindowsLenient(desktops map[uuid.UUID]Desktop, filter WindowFilter) (map[uuid.UUID]Window, []string, error) {
+	return k.getWindows(desktops, filter, true)
+}
+
+// getWindows implements GetWindowsFiltered and GetWindowsLenient. When skipInvalid is false, the first window which
+// fails to parse fails the call
+func (k KWin) getWindows(desktops map[uuid.UUID]Desktop, filter WindowFilter, skipInvalid bool) (map[uuid.UUID]Window, []string, error) {
+	script := windowToJSON + filter.toJS() + `
+	for (const window of workspace.windowList()) {
+		if (!windowMatchesFilter(window)) {
+			continue;
+		}
+		print(windowToJSON(window))
 	}`
 	output, err := k.loadExecuteAndGetOutput(script)
 	if err != nil {
 		fmt.Printf("Error running script for windows list: %v\n", err)
-		return nil, err
+		return nil, nil, err
+	}
+	if len(output) == 0 && filter.IsZero() {
+		// Zero windows is possible, but very unlikely on a running desktop, so it rather hints at missing script output
+		fmt.Printf("Warning: no windows reported, check the script output capture\n")
+	}
+	if err := scriptError(output); err != nil {
+		return nil, nil, err
 	}
 	outputMap := make(map[uuid.UUID]Window)
-	for _, s := range output {
-		d := Window{}
-		ss := strings.ReplaceAll(s, "js: ", "")
-		if err := json.Unmarshal([]byte(ss), &d); err != nil {
-			return nil, err
+	invalid := make([]string, 0)
+	parsed := make([]Window, len(output))
+	parseErrors := make([]error, len(output))
+	pids := make([]int, 0, len(output))
+	for i, s := range output {
+		parsed[i], parseErrors[i] = parseWindowLine(s)
+		if parseErrors[i] == nil {
+			pids = append(pids, parsed[i].Pid)
 		}
-		rawCmdLine, err := k.getProcessCmdLine(d.Pid)
-		if err != nil {
-			fmt.Printf("Can't process windows list: %v\n", err)
-			return nil, err
+	}
+	cmdLines := k.getProcessCmdLines(pids)
+	for i, s := range output {
+		d, err := parsed[i], parseErrors[i]
+		if err == nil {
+			d, err = k.enrichWindow(d, desktops, cmdLines)
 		}
-		cmdLine := strings.Fields(rawCmdLine)[0]
-		d.CmdLine = cmdLine
-		saCmdLine := strings.Split(cmdLine, "/")
-		appName := strings.TrimSpace(saCmdLine[len(saCmdLine)-1])
-		d.AppName = appName
-		if desktops != nil {
-			d.Desktops = make([]Desktop, len(d.DesktopIds))
-			for i := range d.DesktopIds {
-				d.Desktops[i] = desktops[d.DesktopIds[i]]
+		if err != nil {
+			if !skipInvalid {
+				return nil, nil, err
 			}
+			fmt.Printf("Skipping invalid window: %v\n", err)
+			invalid = append(invalid, s)
+			continue
+		}
+		if !filter.Matches(d) {
+			continue
 		}
 		outputMap[uuid.MustParse(d.Id)] = d
 	}
-	return outputMap, nil
+	k.rememberGeometries(outputMap)
+	return outputMap, invalid, nil
+}
+
+// parseWindow converts a single line of script output, using parseWindowLine, and enriches the Window with the
+// process command line, the application name and, when desktops is not nil, the Desktop objects the window is on. For
+// windows without a pid the process is not looked up and the application name falls back to the resource class
+func (k KWin) parseWindow(s string, desktops map[uuid.UUID]Desktop) (Window, error) {
+	d, err := parseWindowLine(s)
+	if err != nil {
+		return Window{}, err
+	}
+	return k.enrichWindow(d, desktops, nil)
+}
+
+// enrichWindow adds the process command line, the application name and, when desktops is not nil, the Desktop objects
+// the window is on to a Window parsed by parseWindowLine. Command lines already read by getProcessCmdLines are taken from
+// cmdLines, any other is read from /proc
+func (k KWin) enrichWindow(d Window, desktops map[uuid.UUID]Desktop, cmdLines map[int]processCmdLine) (Window, error) {
+	if d.Pid > 0 && !k.NoProcLookup {
+		c, ok := cmdLines[d.Pid]
+		if !ok {
+			c.cmdLine, c.err = k.getProcessCmdLine(d.Pid)
+		}
+		rawCmdLine, err := c.cmdLine, c.err
+		if err != nil {
+			fmt.Printf("Can't process windows list: %v\n", err)
+			return Window{}, err
+		}
+		if fields := strings.Fields(rawCmdLine); len(fields) > 0 {
+			cmdLine := fields[0]
+			d.CmdLine = cmdLine
+			saCmdLine := strings.Split(cmdLine, "/")
+			appName := strings.TrimSpace(saCmdLine[len(saCmdLine)-1])
+			d.AppName = appName
+		}
+	}
+	if d.AppName == "" {
+		// Some Wayland native windows report no pid, so there is no process to read the application name from
+		d.AppName = d.ResourceClass
+	}
+	if desktops != nil {
+		d.Desktops = make([]Desktop, len(d.DesktopIds))
+		for i := range d.DesktopIds {
+			d.Desktops[i] = desktops[d.DesktopIds[i]]
+		}
+	}
+	return d, nil
 }
 
 // GetEnvironment is a helper method, which gathers all available Screen, Desktop and Window information and returns it
@@ -443,11 +1035,50 @@ func (k KWin) GetEnvironment() (Environment, error) {
 		fmt.Printf("Error getting windows: %v\n", err)
 		return Environment{}, err
 	}
-	return Environment{
+	env := Environment{
 		Screens:  screens,
 		Desktops: desktops,
 		Windows:  windows,
-	}, nil
+	}
+	if err := k.getActiveState(&env); err != nil {
+		fmt.Printf("Error getting active state: %v\n", err)
+		return Environment{}, err
+	}
+	env.countDesktopWindows()
+	return env, nil
+}
+
+// activeStateToJSON is a JavaScript function, shared by the scripts which report the active state, that serializes the
+// active Window id, the current Desktop id and the active Screen name into the JSON representation of these Environment
+// fields
+const activeStateToJSON = `
+	function activeStateToJSON() {
+		var out = "{"
+		var activeWindow = workspace.activeWindow
+		if (activeWindow) {
+			out += "\"activeWindowId\": \""+activeWindow.internalId.toString().replace(/{/, "").replace(/}/, "")+"\","
+		}
+		out += "\"currentDesktopId\": \""+workspace.currentDesktop.id+"\","
+		out += "\"activeScreen\": \""+(workspace.activeScreen ? workspace.activeScreen.name : "")+"\""
+		out += "}"
+		return out
+	}`
+
+// getActiveState fills in the active Window, the current Desktop and the active Screen of the given Environment
+func (k KWin) getActiveState(env *Environment) error {
+	script := activeStateToJSON + `
+	print(activeStateToJSON())`
+	output, err := k.loadExecuteAndGetOutput(script)
+	if err != nil {
+		return err
+	}
+	if len(output) == 0 {
+		return fmt.Errorf("active state not found: %w", ErrNoScriptOutput)
+	}
+	if err := scriptError(output); err != nil {
+		return err
+	}
+	return json.Unmarshal([]byte(scriptLine(output[0])), env)
 }
 
 // MoveWindowToDesktop will attempt to move a given Window to a given Desktop
@@ -455,22 +1086,91 @@ func (k KWin) MoveWindowToDesktop(w Window, d Desktop) error {
 	return k.MoveWindowToDesktops(w, []Desktop{d})
 }
 
-// MoveWindowToDesktops will attempt to move a given Window to a given array of multiple Desktop's
-//
-//	NOTE: This only works on Wayland. On X11 the window will be moved to the last Desktop in the list
-func (k KWin) MoveWindowToDesktops(w Window, ds []Desktop) error {
+// PinWindowToAllDesktops will attempt to put a given Window on all desktops, including the ones created later
+func (k KWin) PinWindowToAllDesktops(w Window) error {
 	script := `
-    targetDesktopIds = %s;
-	windowId = "%s";
-    var d = [];
-    for (const desktop of workspace.desktops) {
-        if (targetDesktopIds.includes(desktop.id)) {
-            d.push(desktop);
+    windowId = "%s";
+    for (const window of workspace.windowList()) {
+        wid = window.internalId.toString().replace(/{/, "").replace(/}/, "");
+        if (wid === windowId) {
+            window.onAllDesktops = true;
+            break;
         }
-    }
-    if (d && d.length > 0) {
-        var w = undefined;
-        for (const window of workspace.windowList()) {
+    }`
+	return k.runMutation(context.Background(), fmt.Sprintf(script, w.Id))
+}
+
+// MoveWindowToDesktopPreservingScreen will attempt to move a given Window to a given Desktop, like MoveWindowToDesktop,
+// and keep it on the Screen output it was on before the move, as in some multi-monitor configurations KWin may place
+// the window on another output when it changes desktops
+func (k KWin) MoveWindowToDesktopPreservingScreen(w Window, d Desktop) error {
+	script := `
+    targetDesktopId = "%s";
+    windowId = "%s";
+    var d = undefined;
+    for (const desktop of workspace.desktops) {
+        if (desktop.id === targetDesktopId) {
+            d = desktop;
+            break;
+        }
+    }
+    if (d) {
+        for (const window of workspace.windowList()) {
+            wid = window.internalId.toString().replace(/{/, "").replace(/}/, "");
+            if (wid === windowId) {
+                if (window.moveable) {
+                    var output = window.output;
+                    window.desktops = [d];
+                    if (output && (!window.output || window.output.name !== output.name)) {
+                        workspace.sendClientToScreen(window, output);
+                    }
+                }
+                break;
+            }
+        }
+    }`
+	return k.runMutation(context.Background(), fmt.Sprintf(script, d.Id, w.Id))
+}
+
+// MoveWindowToActiveDesktop will attempt to move a given Window to the currently active Desktop. Windows which are on
+// all desktops are left there, as they are already visible on the active one
+func (k KWin) MoveWindowToActiveDesktop(w Window) error {
+	if w.OnAllDesktops {
+		return nil
+	}
+	d, err := k.GetCurrentDesktop()
+	if err != nil {
+		fmt.Printf("Error getting current desktop: %v\n", err)
+		return err
+	}
+	return k.MoveWindowToDesktop(w, d)
+}
+
+// MoveWindowToDesktops will attempt to move a given Window to a given array of multiple Desktop's. An empty array is an
+// error, use PinWindowToAllDesktops to put the Window on all desktops
+//
+//	NOTE: This only works on Wayland. On X11 the window will be moved to the last Desktop in the list
+func (k KWin) MoveWindowToDesktops(w Window, ds []Desktop) error {
+	return k.MoveWindowToDesktopsContext(context.Background(), w, ds)
+}
+
+// MoveWindowToDesktopsContext is the context aware version of MoveWindowToDesktops
+func (k KWin) MoveWindowToDesktopsContext(ctx context.Context, w Window, ds []Desktop) error {
+	if len(ds) == 0 {
+		return fmt.Errorf("no desktops to move window %s to", w.Id)
+	}
+	script := `
+    targetDesktopIds = %s;
+	windowId = "%s";
+    var d = [];
+    for (const desktop of workspace.desktops) {
+        if (targetDesktopIds.includes(desktop.id)) {
+            d.push(desktop);
+        }
+    }
+    if (d && d.length > 0) {
+        var w = undefined;
+        for (const window of workspace.windowList()) {
             wid = window.internalId.toString().replace(/{/, "").replace(/}/, "");
             if (wid === windowId) {
                 w = window;
@@ -489,12 +1189,34 @@ func (k KWin) MoveWindowToDesktops(w Window, ds []Desktop) error {
 		}
 	}
 	targetDesktops += "]"
-	_, err := k.loadExecuteAndGetOutput(fmt.Sprintf(script, targetDesktops, w.Id))
-	return err
+	return k.runMutation(ctx, fmt.Sprintf(script, targetDesktops, w.Id))
+}
+
+// EnsureWindowOnDesktops will move a given Window to a given array of multiple Desktop's, like MoveWindowToDesktops,
+// unless the Window is already on exactly these desktops, in which case no script is run. The check uses the
+// DesktopIds of the given Window, so it is only as current as the Window value
+func (k KWin) EnsureWindowOnDesktops(w Window, ds []Desktop) error {
+	ids := make([]uuid.UUID, 0, len(ds))
+	for _, d := range ds {
+		id, err := uuid.Parse(d.Id)
+		if err != nil {
+			return fmt.Errorf("invalid desktop id %q: %w", d.Id, err)
+		}
+		ids = append(ids, id)
+	}
+	if !w.OnAllDesktops && sameElements(w.DesktopIds, ids) {
+		return nil
+	}
+	return k.MoveWindowToDesktops(w, ds)
 }
 
 // MoveWindowToScreen will attempt to move a given Window to a given Screen output
 func (k KWin) MoveWindowToScreen(w Window, s Screen) error {
+	return k.MoveWindowToScreenContext(context.Background(), w, s)
+}
+
+// MoveWindowToScreenContext is the context aware version of MoveWindowToScreen
+func (k KWin) MoveWindowToScreenContext(ctx context.Context, w Window, s Screen) error {
 	script := `
     targetScreenName = "%s"
     windowId = "%s";
@@ -520,12 +1242,48 @@ func (k KWin) MoveWindowToScreen(w Window, s Screen) error {
         }
     }`
 
-	output, err := k.loadExecuteAndGetOutput(fmt.Sprintf(script, s.Name, w.Id))
-	for _, s := range output {
-		fmt.Println(s)
-	}
+	return k.runMutation(ctx, fmt.Sprintf(script, s.Name, w.Id))
+}
 
-	return err
+// MoveWindowToOutput will attempt to move a given Window to a given Screen output by assigning the output property of
+// the window, instead of calling sendClientToScreen like MoveWindowToScreen does. sendClientToScreen may raise and
+// activate the window, while assigning the output only moves it, so the focus stays where it is.
+//
+//	NOTE: Not every KWin version allows assigning the output, in which case an unsupported error is returned and
+//	MoveWindowToScreen is the way to go
+func (k KWin) MoveWindowToOutput(w Window, s Screen) error {
+	script := `
+    targetScreenName = "%s";
+    windowId = "%s";
+    for (const screen of workspace.screens) {
+        if (screen.name !== targetScreenName) {
+            continue;
+        }
+        for (const window of workspace.windowList()) {
+            wid = window.internalId.toString().replace(/{/, "").replace(/}/, "");
+            if (wid === windowId) {
+                if (window.moveable) {
+                    try {
+                        window.output = screen;
+                    } catch (e) {
+                    }
+                    if (!window.output || window.output.name !== targetScreenName) {
+                        print("%s");
+                    }
+                }
+                break;
+            }
+        }
+        break;
+    }`
+	output, err := k.loadExecuteAndGetOutput(fmt.Sprintf(script, escapeJSString(s.Name), w.Id, unsupportedMarker))
+	if err != nil {
+		return err
+	}
+	if hasMarker(output, unsupportedMarker) {
+		return fmt.Errorf("assigning the window output is not supported by this KWin version")
+	}
+	return nil
 }
 
 // MoveWindowToDesktopsAndScreen will attempt to move a given Window to a given list of Desktop's and to a given Screen
@@ -540,20 +1298,25 @@ func (k KWin) MoveWindowToDesktopsAndScreen(w Window, ds []Desktop, s Screen) er
 
 // MaximizeWindow will attempt to maximize window both horizontally and vertically
 func (k KWin) MaximizeWindow(w Window) error {
-	return k.maximizeWindowHV(w, true, true)
+	return k.MaximizeWindowContext(context.Background(), w)
+}
+
+// MaximizeWindowContext is the context aware version of MaximizeWindow
+func (k KWin) MaximizeWindowContext(ctx context.Context, w Window) error {
+	return k.maximizeWindowHV(ctx, w, true, true)
 }
 
 // MaximizeWindowHorizontally will attempt to maximize window horizontally
 func (k KWin) MaximizeWindowHorizontally(w Window) error {
-	return k.maximizeWindowHV(w, true, false)
+	return k.maximizeWindowHV(context.Background(), w, true, false)
 }
 
 // MaximizeWindowVertically will attempt to maximize window vertically
 func (k KWin) MaximizeWindowVertically(w Window) error {
-	return k.maximizeWindowHV(w, false, true)
+	return k.maximizeWindowHV(context.Background(), w, false, true)
 }
 
-func (k KWin) maximizeWindowHV(w Window, maximizeHorizontally, maximizeVertically bool) error {
+func (k KWin) maximizeWindowHV(ctx context.Context, w Window, maximizeHorizontally, maximizeVertically bool) error {
 	script := `
     windowId = "%s";
     maximizeHorizontally = %v;
@@ -566,16 +1329,74 @@ func (k KWin) maximizeWindowHV(w Window, maximizeHorizontally, maximizeVerticall
         }
     }`
 	command := fmt.Sprintf(script, w.Id, maximizeHorizontally, maximizeVertically)
-	output, err := k.loadExecuteAndGetOutput(command)
-	for _, s := range output {
-		fmt.Println(s)
+	return k.runMutation(ctx, command)
+}
+
+// MaximizeWindowOnScreen will attempt to move a given Window to a given Screen output and maximize it there, in a
+// single script, so there is no visible intermediate step
+func (k KWin) MaximizeWindowOnScreen(w Window, s Screen) error {
+	script := `
+    targetScreenName = "%s";
+    windowId = "%s";
+    for (const screen of workspace.screens) {
+        if (screen.name !== targetScreenName) {
+            continue;
+        }
+        for (const window of workspace.windowList()) {
+            wid = window.internalId.toString().replace(/{/, "").replace(/}/, "");
+            if (wid === windowId) {
+                if (window.moveable) {
+                    workspace.sendClientToScreen(window, screen);
+                }
+                window.setMaximize(true, true);
+                break;
+            }
+        }
+        break;
+    }`
+	return k.runMutation(context.Background(), fmt.Sprintf(script, escapeJSString(s.Name), w.Id))
+}
+
+// MaximizeWindowToWorkArea will attempt to make a given Window fill the work area of a given Screen (see GetWorkArea),
+// by setting its geometry instead of maximizing it. The window keeps its decoration and is not in the maximized state,
+// which some themes render differently. A maximized window is restored first, as KWin ignores geometry changes of
+// maximized windows
+func (k KWin) MaximizeWindowToWorkArea(w Window, s Screen) error {
+	r, err := k.GetWorkArea(s)
+	if err != nil {
+		fmt.Printf("Error getting work area: %v\n", err)
+		return err
 	}
+	if w.MaximizedHorizontally || w.MaximizedVertically {
+		if err := k.maximizeWindowHV(context.Background(), w, false, false); err != nil {
+			return err
+		}
+	}
+	return k.SetWindowGeometry(w, r)
+}
 
-	return err
+// SetWindowFullscreen will attempt to make a given Window fullscreen (true) or to leave the fullscreen state (false).
+// KWin may apply the change asynchronously, see WaitForWindowState
+func (k KWin) SetWindowFullscreen(w Window, fullscreen bool) error {
+	script := `
+    windowId = "%s";
+    for (const window of workspace.windowList()) {
+        wid = window.internalId.toString().replace(/{/, "").replace(/}/, "");
+        if (wid === windowId) {
+            window.fullScreen = %t;
+            break;
+        }
+    }`
+	return k.runMutation(context.Background(), fmt.Sprintf(script, w.Id, fullscreen))
 }
 
 // MinimizeWindow will attempt to minimize window
 func (k KWin) MinimizeWindow(w Window) error {
+	return k.MinimizeWindowContext(context.Background(), w)
+}
+
+// MinimizeWindowContext is the context aware version of MinimizeWindow
+func (k KWin) MinimizeWindowContext(ctx context.Context, w Window) error {
 	script := `
     windowId = "%s";
     for (const window of workspace.windowList()) {
@@ -586,38 +1407,27 @@ func (k KWin) MinimizeWindow(w Window) error {
         }
     }`
 	command := fmt.Sprintf(script, w.Id)
-	output, err := k.loadExecuteAndGetOutput(command)
-	for _, s := range output {
-		fmt.Println(s)
-	}
-
-	return err
+	return k.runMutation(ctx, command)
 }
 
 // SetWindowDemandsAttention will attempt to set the window state of demanding user attention to the specified value
 func (k KWin) SetWindowDemandsAttention(w Window, demandsAttention bool) error {
+	return k.SetWindowDemandsAttentionContext(context.Background(), w, demandsAttention)
+}
+
+// SetWindowDemandsAttentionContext is the context aware version of SetWindowDemandsAttention
+func (k KWin) SetWindowDemandsAttentionContext(ctx context.Context, w Window, demandsAttention bool) error {
 	script := `
-		windowId = "%s";
-		for (const window of workspace.windowList()) {
-			var w = undefined;
-			for (const window of workspace.windowList()) {
-				wid = window.internalId.toString().replace(/{/, "").replace(/}/, "");
-				if (wid === windowId) {
-					w = window;
-					break;
-				}
-			}
-			if (w) {
-				w.demandsAttention = %t;
-			}
-		}`
+    windowId = "%s";
+    for (const window of workspace.windowList()) {
+        wid = window.internalId.toString().replace(/{/, "").replace(/}/, "");
+        if (wid === windowId) {
+            window.demandsAttention = %t;
+            break;
+        }
+    }`
 	command := fmt.Sprintf(script, w.Id, demandsAttention)
-	output, err := k.loadExecuteAndGetOutput(command)
-	for _, s := range output {
-		fmt.Println(s)
-	}
-
-	return err
+	return k.runMutation(ctx, command)
 }
 
 // WindowDemandAttention will attempt to set the given window to demand user attention
@@ -629,3 +1439,488 @@ func (k KWin) WindowDemandAttention(w Window) error {
 func (k KWin) WindowUnDemandAttention(w Window) error {
 	return k.SetWindowDemandsAttention(w, false)
 }
+
+// WindowUnderCursor returns the topmost Window on the current desktop that contains the mouse cursor, respecting the
+// window stacking order. The returned bool is false when the cursor is over the desktop background or over a special
+// window, like a panel
+func (k KWin) WindowUnderCursor() (Window, bool, error) {
+	script := windowToJSON + `
+	var pos = workspace.cursorPos;
+	var stack = workspace.stackingOrder;
+	for (var i = stack.length-1; i >= 0; i--) {
+		var window = stack[i];
+		if (window.minimized) {
+			continue;
+		}
+		var onCurrentDesktop = window.onAllDesktops;
+		for (const d of window.desktops) {
+			if (d.id === workspace.currentDesktop.id) {
+				onCurrentDesktop = true;
+			}
+		}
+		if (!onCurrentDesktop) {
+			continue;
+		}
+		if (pos.x < window.x || pos.x >= window.x+window.width || pos.y < window.y || pos.y >= window.y+window.height) {
+			continue;
+		}
+		if (!window.specialWindow) {
+			print(windowToJSON(window))
+		}
+		break;
+	}`
+	output, err := k.loadExecuteAndGetOutput(script)
+	if err != nil {
+		fmt.Printf("Error running script for window under cursor: %v\n", err)
+		return Window{}, false, err
+	}
+	if err := scriptError(output); err != nil {
+		return Window{}, false, err
+	}
+	if len(output) == 0 {
+		return Window{}, false, nil
+	}
+	w, err := k.parseWindow(output[0], nil)
+	if err != nil {
+		return Window{}, false, err
+	}
+	return w, true, nil
+}
+
+// RefreshWindow returns the current state of a given Window, read again from KWin and enriched like in GetWindows. It
+// returns an error when the window does not exist anymore
+func (k KWin) RefreshWindow(w Window) (Window, error) {
+	script := windowToJSON + `
+    windowId = "%s";
+    for (const window of workspace.windowList()) {
+        wid = window.internalId.toString().replace(/{/, "").replace(/}/, "");
+        if (wid === windowId) {
+            print(windowToJSON(window));
+            break;
+        }
+    }`
+	output, err := k.loadExecuteAndGetOutput(fmt.Sprintf(script, w.Id))
+	if err != nil {
+		fmt.Printf("Error running script for window refresh: %v\n", err)
+		return Window{}, err
+	}
+	if err := scriptError(output); err != nil {
+		return Window{}, err
+	}
+	if len(output) == 0 {
+		return Window{}, fmt.Errorf("window %s not found", w.Id)
+	}
+	return k.parseWindow(output[0], nil)
+}
+
+// GetCursorPosition returns the current position of the mouse cursor in the global (all screens) coordinate space
+func (k KWin) GetCursorPosition() (Point, error) {
+	script := `
+	var pos = workspace.cursorPos;
+	print("{\"x\": "+pos.x+", \"y\": "+pos.y+"}")`
+	output, err := k.loadExecuteAndGetOutput(script)
+	if err != nil {
+		fmt.Printf("Error running script for cursor position: %v\n", err)
+		return Point{}, err
+	}
+	if len(output) == 0 {
+		return Point{}, fmt.Errorf("cursor position not found: %w", ErrNoScriptOutput)
+	}
+	p := Point{}
+	if err := json.Unmarshal([]byte(scriptLine(output[0])), &p); err != nil {
+		return Point{}, err
+	}
+	return p, nil
+}
+
+// GetWorkspaceGeometry returns the bounding rectangle of all screens together, i.e. the whole area where windows can be
+// placed
+func (k KWin) GetWorkspaceGeometry() (Rect, error) {
+	script := `
+	var geometry = workspace.virtualScreenGeometry;
+	var out = "{"
+	out += "\"topLeft\": {"
+	out += "\"x\":"+geometry.left+","
+	out += "\"y\":"+geometry.top
+	out += "},"
+	out += "\"bottomRight\": {"
+	out += "\"x\":"+geometry.right+","
+	out += "\"y\":"+geometry.bottom
+	out += "}"
+	out += "}"
+	print(out)`
+	output, err := k.loadExecuteAndGetOutput(script)
+	if err != nil {
+		fmt.Printf("Error running script for workspace geometry: %v\n", err)
+		return Rect{}, err
+	}
+	if len(output) == 0 {
+		return Rect{}, fmt.Errorf("workspace geometry not found: %w", ErrNoScriptOutput)
+	}
+	r := Rect{}
+	if err := json.Unmarshal([]byte(scriptLine(output[0])), &r); err != nil {
+		return Rect{}, err
+	}
+	return r, nil
+}
+
+// GetWorkArea returns the usable area of a given Screen on the current Desktop, i.e. the Screen geometry without the
+// panels and other docks which reserve space, where maximized windows go. Tiling and placing windows should use it
+// instead of the Screen geometry, which overlaps the panels
+func (k KWin) GetWorkArea(s Screen) (Rect, error) {
+	return k.getWorkArea(s, nil)
+}
+
+// GetWorkAreaForDesktop returns the usable area of a given Screen on a given Desktop, like GetWorkArea. The areas of
+// the desktops only differ when a panel or dock is not on all desktops
+func (k KWin) GetWorkAreaForDesktop(s Screen, d Desktop) (Rect, error) {
+	return k.getWorkArea(s, &d)
+}
+
+func (k KWin) getWorkArea(s Screen, d *Desktop) (Rect, error) {
+	script := `
+	targetScreenName = "%s";
+	targetDesktopId = "%s";
+	var desktop = workspace.currentDesktop;
+	for (const d of workspace.desktops) {
+		if (d.id === targetDesktopId) {
+			desktop = d;
+			break;
+		}
+	}
+	for (const screen of workspace.screens) {
+		if (screen.name !== targetScreenName) {
+			continue;
+		}
+		var area = workspace.clientArea(KWin.MaximizeArea, screen, desktop);
+		var out = "{"
+		out += "\"topLeft\": {\"x\": "+Math.round(area.x)+", \"y\": "+Math.round(area.y)+"},"
+		out += "\"bottomRight\": {\"x\": "+Math.round(area.x+area.width)+", \"y\": "+Math.round(area.y+area.height)+"}"
+		out += "}"
+		print(out)
+		break;
+	}`
+	desktopId := ""
+	if d != nil {
+		desktopId = d.Id
+	}
+	output, err := k.loadExecuteAndGetOutput(fmt.Sprintf(script, escapeJSString(s.Name), desktopId))
+	if err != nil {
+		fmt.Printf("Error running script for work area: %v\n", err)
+		return Rect{}, err
+	}
+	if len(output) == 0 {
+		return Rect{}, fmt.Errorf("work area of screen %s not found", s.Name)
+	}
+	r := Rect{}
+	if err := json.Unmarshal([]byte(scriptLine(output[0])), &r); err != nil {
+		return Rect{}, err
+	}
+	return r, nil
+}
+
+// Validate checks that the scripting output can be captured, by running a trivial script which prints a unique marker
+// and looking for it in the gathered output. KWin only routes script output to the journal when debug logging is
+// enabled for its categories, so when the marker is missing the returned error instructs the user to set
+// QT_LOGGING_RULES. Without this check a misconfigured system simply looks like it has no screens, desktops or windows.
+// A session of another desktop environment is reported first, see CheckDesktopEnvironment
+func (k KWin) Validate() error {
+	if err := CheckDesktopEnvironment(); err != nil {
+		return err
+	}
+	script := `
+	print("%s")`
+	marker := "#validate-" + uuid.NewString()
+	output, err := k.loadExecuteAndGetOutput(fmt.Sprintf(script, marker))
+	if err != nil {
+		fmt.Printf("Error running validation script: %v\n", err)
+		return err
+	}
+	for _, s := range output {
+		if strings.Contains(s, marker) {
+			return nil
+		}
+	}
+	return fmt.Errorf("no KWin script output found in the journal, make sure QT_LOGGING_RULES=\"kwin_*.debug=true\" is " +
+		"set in the environment of the Plasma session and log in again")
+}
+
+// GetWindowStackingPosition returns the position of a given Window in the stacking order, where higher positions are
+// stacked above lower ones. It can be used to restore the window ordering after a batch of raises and lowers
+func (k KWin) GetWindowStackingPosition(w Window) (int, error) {
+	script := `
+    windowId = "%s";
+    var stack = workspace.stackingOrder;
+    for (var i = 0; i < stack.length; i++) {
+        wid = stack[i].internalId.toString().replace(/{/, "").replace(/}/, "");
+        if (wid === windowId) {
+            print(i);
+            break;
+        }
+    }`
+	output, err := k.loadExecuteAndGetOutput(fmt.Sprintf(script, w.Id))
+	if err != nil {
+		fmt.Printf("Error running script for window stacking position: %v\n", err)
+		return -1, err
+	}
+	if len(output) == 0 {
+		return -1, fmt.Errorf("window %s not found", w.Id)
+	}
+	return strconv.Atoi(scriptLine(output[0]))
+}
+
+// RaiseWindow will attempt to raise a given Window to the top of the stacking order, without activating it or giving it
+// the focus
+func (k KWin) RaiseWindow(w Window) error {
+	return k.restackWindow(w, "raiseWindow")
+}
+
+// LowerWindow will attempt to lower a given Window to the bottom of the stacking order, without changing the focus
+func (k KWin) LowerWindow(w Window) error {
+	return k.restackWindow(w, "lowerWindow")
+}
+
+func (k KWin) restackWindow(w Window, method string) error {
+	script := `
+    windowId = "%s";
+    for (const window of workspace.windowList()) {
+        wid = window.internalId.toString().replace(/{/, "").replace(/}/, "");
+        if (wid === windowId) {
+            if (typeof workspace.%s !== "function") {
+                print("%s");
+            } else {
+                workspace.%s(window);
+            }
+            break;
+        }
+    }`
+	command := fmt.Sprintf(script, w.Id, method, unsupportedMarker, method)
+	output, err := k.loadExecuteAndGetOutput(command)
+	if err != nil {
+		return err
+	}
+	if hasMarker(output, unsupportedMarker) {
+		return fmt.Errorf("workspace.%s is not supported by this KWin version", method)
+	}
+	return nil
+}
+
+// hasMarker reports whether the given script output contains a line with the given marker
+func hasMarker(output []string, marker string) bool {
+	for _, s := range output {
+		if scriptLine(s) == marker {
+			return true
+		}
+	}
+	return false
+}
+
+// GetWindowCaption returns the current caption (title) of a given Window, without enumerating all windows. Captions
+// change often (e.g. browser tabs, progress indicators), so this is a cheap way to poll them
+func (k KWin) GetWindowCaption(w Window) (string, error) {
+	script := `
+    windowId = "%s";
+    for (const window of workspace.windowList()) {
+        wid = window.internalId.toString().replace(/{/, "").replace(/}/, "");
+        if (wid === windowId) {
+            print(JSON.stringify(window.caption));
+            break;
+        }
+    }`
+	output, err := k.loadExecuteAndGetOutput(fmt.Sprintf(script, w.Id))
+	if err != nil {
+		fmt.Printf("Error running script for window caption: %v\n", err)
+		return "", err
+	}
+	if len(output) == 0 {
+		return "", fmt.Errorf("window %s not found", w.Id)
+	}
+	caption := ""
+	if err := json.Unmarshal([]byte(scriptLine(output[0])), &caption); err != nil {
+		return "", err
+	}
+	return caption, nil
+}
+
+// SetWindowCaption will attempt to change the caption (title) of a given Window.
+//
+//	NOTE: The caption is owned by the client program and KWin 6 exposes it to scripts as a read-only property, so on
+//	current KWin versions this returns an unsupported error. The attempt is kept, so it starts working should a future
+//	KWin version make the caption writable
+func (k KWin) SetWindowCaption(w Window, caption string) error {
+	script := `
+    windowId = "%s";
+    caption = "%s";
+    for (const window of workspace.windowList()) {
+        wid = window.internalId.toString().replace(/{/, "").replace(/}/, "");
+        if (wid === windowId) {
+            try {
+                window.caption = caption;
+            } catch (e) {
+            }
+            if (window.caption !== caption) {
+                print("%s");
+            }
+            break;
+        }
+    }`
+	output, err := k.loadExecuteAndGetOutput(fmt.Sprintf(script, w.Id, escapeJSString(caption), unsupportedMarker))
+	if err != nil {
+		return err
+	}
+	if hasMarker(output, unsupportedMarker) {
+		return fmt.Errorf("setting the window caption is not supported by this KWin version")
+	}
+	return nil
+}
+
+// ToggleWindowMinimized will attempt to minimize a given Window when it is not minimized and to restore it otherwise.
+// The state is read and flipped inside the script, like a keyboard shortcut does, and the resulting minimized state is
+// returned
+func (k KWin) ToggleWindowMinimized(w Window) (bool, error) {
+	script := `
+    windowId = "%s";
+    for (const window of workspace.windowList()) {
+        wid = window.internalId.toString().replace(/{/, "").replace(/}/, "");
+        if (wid === windowId) {
+            window.minimized = !window.minimized;
+            print(window.minimized);
+            break;
+        }
+    }`
+	return k.toggleWindowState(fmt.Sprintf(script, w.Id), w)
+}
+
+// ToggleWindowMaximized will attempt to maximize a given Window when it is not maximized both ways and to restore it
+// otherwise. The state is read and flipped inside the script, like a keyboard shortcut does, and the resulting
+// maximized state is returned
+func (k KWin) ToggleWindowMaximized(w Window) (bool, error) {
+	script := windowMaximizeMode + `
+    windowId = "%s";
+    for (const window of workspace.windowList()) {
+        wid = window.internalId.toString().replace(/{/, "").replace(/}/, "");
+        if (wid === windowId) {
+            var maximize = windowMaximizeMode(window) !== 3;
+            window.setMaximize(maximize, maximize);
+            print(maximize);
+            break;
+        }
+    }`
+	return k.toggleWindowState(fmt.Sprintf(script, w.Id), w)
+}
+
+// toggleWindowState executes a toggle script, which prints the resulting state of the window, and returns that state
+func (k KWin) toggleWindowState(script string, w Window) (bool, error) {
+	output, err := k.loadExecuteAndGetOutput(script)
+	if err != nil {
+		return false, err
+	}
+	if len(output) == 0 {
+		return false, fmt.Errorf("window %s not found", w.Id)
+	}
+	return strconv.ParseBool(scriptLine(output[0]))
+}
+
+// MoveWindowToScreenIndex will attempt to move a given Window to the Screen output at the given position in KWin's list
+// of screens, as reported by Screen.Index.
+//
+//	NOTE: Output names (e.g. "DP-1") depend on the connector and the driver and may change when monitors are
+//	reconnected through docks or adapters, while the index follows the order in which KWin lists the outputs, which is
+//	stable as long as the same set of monitors is connected, but shifts when one is added or removed. Use
+//	MoveWindowToScreen to address screens by name and this method to address them by position
+func (k KWin) MoveWindowToScreenIndex(w Window, index int) error {
+	script := `
+    targetScreenIndex = %d;
+    windowId = "%s";
+
+    if (targetScreenIndex < workspace.screens.length) {
+        var s = workspace.screens[targetScreenIndex];
+        for (const window of workspace.windowList()) {
+            wid = window.internalId.toString().replace(/{/, "").replace(/}/, "");
+            if (wid === windowId) {
+                if (window.moveable) {
+                    workspace.sendClientToScreen(window, s);
+                }
+                break;
+            }
+        }
+    } else {
+        print("%s");
+    }`
+	if index < 0 {
+		return fmt.Errorf("invalid screen index: %d", index)
+	}
+	output, err := k.loadExecuteAndGetOutput(fmt.Sprintf(script, index, w.Id, notFoundMarker))
+	if err != nil {
+		return err
+	}
+	if hasMarker(output, notFoundMarker) {
+		return fmt.Errorf("screen index %d out of range", index)
+	}
+	return nil
+}
+
+// SetWindowGeometry will attempt to move and resize a given Window so its frame covers the given Rect in logical
+// pixels. Windows which are not moveable are left alone, windows which are not resizeable are only moved
+func (k KWin) SetWindowGeometry(w Window, r Rect) error {
+	script := `
+    windowId = "%s";
+    for (const window of workspace.windowList()) {
+        wid = window.internalId.toString().replace(/{/, "").replace(/}/, "");
+        if (wid === windowId) {
+            if (window.moveable) {
+                var g = window.frameGeometry;
+                var width = window.resizeable ? %d : g.width;
+                var height = window.resizeable ? %d : g.height;
+                window.frameGeometry = {x: %d, y: %d, width: width, height: height};
+            }
+            break;
+        }
+    }`
+	width := r.BottomRight.X - r.TopLeft.X
+	height := r.BottomRight.Y - r.TopLeft.Y
+	if width <= 0 || height <= 0 {
+		return fmt.Errorf("invalid window geometry: %v", r)
+	}
+	return k.runMutation(context.Background(), fmt.Sprintf(script, w.Id, width, height, r.TopLeft.X, r.TopLeft.Y))
+}
+
+// MoveWindowToScreenFraction will attempt to move and resize a given Window to the rectangle described by fractions
+// (0..1) of the given Screen geometry, e.g. x=0, y=0, width=0.6, height=1 is the left 60% of the screen. See
+// Screen.FractionRect for how the fractions are converted
+func (k KWin) MoveWindowToScreenFraction(w Window, s Screen, x, y, width, height float64) error {
+	r, err := s.FractionRect(x, y, width, height)
+	if err != nil {
+		return err
+	}
+	return k.SetWindowGeometry(w, r)
+}
+
+// SetWindowNoBorder will attempt to remove (true) or restore (false) the decoration (title bar and border) of a given
+// Window.
+//
+//	NOTE: This is best effort. KWin ignores the request for windows which don't use server-side decorations (they draw
+//	their own title bar) or which don't allow the border to be changed, in which case an unsupported error is returned
+func (k KWin) SetWindowNoBorder(w Window, noBorder bool) error {
+	script := `
+    windowId = "%s";
+    noBorder = %t;
+    for (const window of workspace.windowList()) {
+        wid = window.internalId.toString().replace(/{/, "").replace(/}/, "");
+        if (wid === windowId) {
+            window.noBorder = noBorder;
+            if (window.noBorder !== noBorder) {
+                print("%s");
+            }
+            break;
+        }
+    }`
+	output, err := k.loadExecuteAndGetOutput(fmt.Sprintf(script, w.Id, noBorder, unsupportedMarker))
+	if err != nil {
+		return err
+	}
+	if hasMarker(output, unsupportedMarker) {
+		return fmt.Errorf("changing the border of window %s is not supported", w.Id)
+	}
+	return nil
+}
@@ -0,0 +1,92 @@
+package go_kwin6
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// defaultPollInterval is the interval in which the waiting helpers re-read the windows, when no interval is given
+const defaultPollInterval = 250 * time.Millisecond
+
+// waitForWindow polls the windows matching the filter in the given interval until pick selects one of them, or ctx is
+// done
+func (k KWin) waitForWindow(ctx context.Context, filter WindowFilter, poll time.Duration, pick func(map[uuid.UUID]Window) (Window, bool)) (Window, error) {
+	if poll <= 0 {
+		poll = defaultPollInterval
+	}
+	for {
+		windows, err := k.GetWindowsFiltered(nil, filter)
+		if err != nil {
+			return Window{}, err
+		}
+		if w, ok := pick(windows); ok {
+			return w, nil
+		}
+		select {
+		case <-ctx.Done():
+			return Window{}, ctx.Err()
+		case <-time.After(poll):
+		}
+	}
+}
+
+// WaitForWindow waits until a Window matching the given filter exists and returns it. The windows are re-read in the
+// given poll interval (a default is used when it is not positive) until a match is found or ctx is done
+func (k KWin) WaitForWindow(ctx context.Context, match WindowFilter, poll time.Duration) (Window, error) {
+	return k.waitForWindow(ctx, match, poll, func(windows map[uuid.UUID]Window) (Window, bool) {
+		matching := match.Filter(windows)
+		if len(matching) == 0 {
+			return Window{}, false
+		}
+		return matching[0], true
+	})
+}
+
+// LaunchAndTrack starts the given command and waits until its Window appears, which it returns. Only windows which
+// didn't exist before the launch are considered. A new window owned by the started process, or by any of its descendant
+// processes, is preferred. When there is none (e.g. the process handed over to an already running instance), a new
+// window matching the filter is accepted, provided the filter is not empty. The started process is not tied to ctx and
+// keeps running after ctx is done
+func (k KWin) LaunchAndTrack(ctx context.Context, command string, args []string, match WindowFilter) (Window, error) {
+	before, err := k.GetWindowsFiltered(nil, match)
+	if err != nil {
+		fmt.Printf("Error getting windows: %v\n", err)
+		return Window{}, err
+	}
+	cmd := exec.Command(command, args...)
+	if err := cmd.Start(); err != nil {
+		fmt.Printf("Error starting %s: %v\n", command, err)
+		return Window{}, err
+	}
+	pid := cmd.Process.Pid
+	go func() {
+		_ = cmd.Wait()
+	}()
+
+	return k.waitForWindow(ctx, match, defaultPollInterval, func(windows map[uuid.UUID]Window) (Window, bool) {
+		parents, err := k.getProcessParents()
+		if err != nil {
+			parents = map[int]int{}
+		}
+		var candidate *Window
+		for _, w := range match.Filter(windows) {
+			if _, ok := before[uuid.MustParse(w.Id)]; ok {
+				continue
+			}
+			if isDescendant(parents, w.Pid, pid) {
+				return w, true
+			}
+			if candidate == nil && !match.IsZero() {
+				candidate = &w
+			}
+		}
+		if candidate != nil {
+			return *candidate, true
+		}
+		return Window{}, false
+	})
+}
@@ -0,0 +1,51 @@
+package go_kwin6
+
+import (
+	"context"
+	"fmt"
+)
+
+const (
+	// LayerBelow keeps the window below the normal windows (keep below)
+	LayerBelow Layer = "below"
+	// LayerNormal is the layer of the ordinary windows, neither kept above nor below
+	LayerNormal Layer = "normal"
+	// LayerAbove keeps the window above the normal windows (keep above). The active fullscreen window is still stacked
+	// above it
+	LayerAbove Layer = "above"
+	// LayerAboveFullscreen would keep the window above everything, including the active fullscreen window. KWin puts
+	// only notifications, on screen displays and similar window types there, and a script can't change the type of a
+	// window, so SetWindowLayer returns an unsupported error for it
+	LayerAboveFullscreen Layer = "aboveFullscreen"
+)
+
+// Layer describes the stacking layer of a Window. KWin stacks the windows in layers (desktop, below, normal, above,
+// active fullscreen, notifications, on screen displays, popups etc.), but its scripting only lets a window move between
+// the below, normal and above layers, by the keepBelow and keepAbove properties. The other layers follow from the window
+// type and state, which the program owning the window sets
+type Layer string
+
+// SetWindowLayer will attempt to move a given Window to a given Layer. LayerBelow, LayerNormal and LayerAbove are
+// honored, LayerAboveFullscreen returns an unsupported error. A window which should stay visible over a fullscreen
+// video, like a picture-in-picture player, needs to be a window type KWin stacks there (e.g. a notification or on
+// screen display), which only the program owning the window can request, or a KWin window rule forcing that type
+func (k KWin) SetWindowLayer(w Window, layer Layer) error {
+	script := `
+    windowId = "%s";
+    for (const window of workspace.windowList()) {
+        wid = window.internalId.toString().replace(/{/, "").replace(/}/, "");
+        if (wid === windowId) {
+            window.keepAbove = %t;
+            window.keepBelow = %t;
+            break;
+        }
+    }`
+	switch layer {
+	case LayerBelow, LayerNormal, LayerAbove:
+	case LayerAboveFullscreen:
+		return fmt.Errorf("window layer %s is not supported by KWin scripting", layer)
+	default:
+		return fmt.Errorf("unknown window layer: %s", layer)
+	}
+	return k.runMutation(context.Background(), fmt.Sprintf(script, w.Id, layer == LayerAbove, layer == LayerBelow))
+}
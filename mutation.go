@@ -0,0 +1,146 @@
+package go_kwin6
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// MutationResult is a struct that describes what a mutator did to a Window. Found is false when the window does not
+// exist anymore, Applied is false when the window was found, but the change could not be made (e.g. the window is not
+// moveable). Window is the state of the window after the change, it is only set when the window was found
+type MutationResult struct {
+	Found   bool   `json:"found"`
+	Applied bool   `json:"applied"`
+	Window  Window `json:"window"`
+}
+
+// runWindowMutation executes the given JavaScript action function on a given Window, like runWindowBatch does for many
+// windows. The action receives the KWin::Window and returns true when it was applied. The state of the window after the
+// action is reported back, enriched with the process information like in GetWindows
+func (k KWin) runWindowMutation(w Window, action string) (MutationResult, error) {
+	script := windowToJSON + `
+    windowId = "%s";
+    var act = %s;
+    var out = "{\"found\": false, \"applied\": false}";
+    for (const window of workspace.windowList()) {
+        var wid = window.internalId.toString().replace(/{/, "").replace(/}/, "");
+        if (wid === windowId) {
+            var applied = act(window) ? true : false;
+            out = "{\"found\": true, \"applied\": "+applied+", \"window\": "+windowToJSON(window)+"}";
+            break;
+        }
+    }
+    print(out);`
+	output, err := k.loadExecuteAndGetOutput(fmt.Sprintf(script, w.Id, action))
+	if err != nil {
+		fmt.Printf("Error running mutation script: %v\n", err)
+		return MutationResult{}, err
+	}
+	if err := scriptError(output); err != nil {
+		return MutationResult{}, err
+	}
+	if len(output) == 0 {
+		return MutationResult{}, fmt.Errorf("mutation result not found: %w", ErrNoScriptOutput)
+	}
+	raw := struct {
+		Found   bool            `json:"found"`
+		Applied bool            `json:"applied"`
+		Window  json.RawMessage `json:"window"`
+	}{}
+	if err := json.Unmarshal([]byte(scriptLine(output[len(output)-1])), &raw); err != nil {
+		return MutationResult{}, err
+	}
+	result := MutationResult{Found: raw.Found, Applied: raw.Applied}
+	if raw.Found {
+		result.Window, err = k.parseWindow(string(raw.Window), nil)
+		if err != nil {
+			return MutationResult{}, err
+		}
+	}
+	return result, nil
+}
+
+// MoveWindowToDesktopResult will attempt to move a given Window to a given Desktop, like MoveWindowToDesktop, and
+// reports whether the window was found and moved, together with its new state. Windows which are not moveable are not
+// moved
+func (k KWin) MoveWindowToDesktopResult(w Window, d Desktop) (MutationResult, error) {
+	action := `function(window) {
+        if (!window.moveable) {
+            return false;
+        }
+        for (const desktop of workspace.desktops) {
+            if (desktop.id === "%s") {
+                window.desktops = [desktop];
+                return window.desktops.length === 1 && window.desktops[0].id === desktop.id;
+            }
+        }
+        return false;
+    }`
+	return k.runWindowMutation(w, fmt.Sprintf(action, d.Id))
+}
+
+// MoveWindowToScreenResult will attempt to move a given Window to a given Screen output, like MoveWindowToScreen, and
+// reports whether the window was found and moved, together with its new state
+func (k KWin) MoveWindowToScreenResult(w Window, s Screen) (MutationResult, error) {
+	action := `function(window) {
+        if (!window.moveable) {
+            return false;
+        }
+        for (const screen of workspace.screens) {
+            if (screen.name === "%s") {
+                workspace.sendClientToScreen(window, screen);
+                return window.output && window.output.name === screen.name;
+            }
+        }
+        return false;
+    }`
+	return k.runWindowMutation(w, fmt.Sprintf(action, escapeJSString(s.Name)))
+}
+
+// SetWindowGeometryResult will attempt to move and resize a given Window, like SetWindowGeometry, and reports whether
+// the window was found and changed, together with its new state. A window which is not resizeable, but moveable, is
+// only moved and the change counts as applied
+func (k KWin) SetWindowGeometryResult(w Window, r Rect) (MutationResult, error) {
+	action := `function(window) {
+        if (!window.moveable) {
+            return false;
+        }
+        var g = window.frameGeometry;
+        var width = window.resizeable ? %d : g.width;
+        var height = window.resizeable ? %d : g.height;
+        window.frameGeometry = {x: %d, y: %d, width: width, height: height};
+        return true;
+    }`
+	width := r.BottomRight.X - r.TopLeft.X
+	height := r.BottomRight.Y - r.TopLeft.Y
+	if width <= 0 || height <= 0 {
+		return MutationResult{}, fmt.Errorf("invalid window geometry: %v", r)
+	}
+	return k.runWindowMutation(w, fmt.Sprintf(action, width, height, r.TopLeft.X, r.TopLeft.Y))
+}
+
+// MaximizeWindowResult will attempt to maximize a given Window, like MaximizeWindow, and reports whether the window was
+// found and maximized, together with its new state
+func (k KWin) MaximizeWindowResult(w Window) (MutationResult, error) {
+	action := `function(window) {
+        if (!window.maximizable) {
+            return false;
+        }
+        window.setMaximize(true, true);
+        return true;
+    }`
+	return k.runWindowMutation(w, action)
+}
+
+// MinimizeWindowResult will attempt to minimize a given Window, like MinimizeWindow, and reports whether the window was
+// found and minimized, together with its new state
+func (k KWin) MinimizeWindowResult(w Window) (MutationResult, error) {
+	action := `function(window) {
+        if (!window.minimizable) {
+            return false;
+        }
+        window.minimized = true;
+        return window.minimized;
+    }`
+	return k.runWindowMutation(w, action)
+}
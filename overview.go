@@ -0,0 +1,79 @@
+package go_kwin6
+
+import (
+	"context"
+	"fmt"
+	"slices"
+)
+
+const (
+	// overviewEffect is the name of the KWin effect behind the overview
+	overviewEffect = "overview"
+	// overviewShortcut is the name of the KWin global shortcut which toggles the overview
+	overviewShortcut = "Overview"
+	// windowSwitcherShortcut is the name of the KWin global shortcut which opens the window switcher (Alt+Tab)
+	windowSwitcherShortcut = "Walk Through Windows"
+)
+
+// getKWinShortcutNames returns the names of the global shortcuts KWin registered with kglobalaccel
+func (k KWin) getKWinShortcutNames() ([]string, error) {
+	output, err := k.callDbusSend(context.Background(),
+		"--print-reply",
+		"--dest=org.kde.kglobalaccel",
+		"/component/kwin", "org.kde.kglobalaccel.Component.shortcutNames")
+	if err != nil {
+		fmt.Printf("Error getting shortcut names: %v\n", err)
+		return nil, err
+	}
+	names := make([]string, 0)
+	for _, s := range output {
+		if v, ok := dbusStringValue(s); ok {
+			names = append(names, v)
+		}
+	}
+	return names, nil
+}
+
+// invokeKWinShortcut triggers the KWin global shortcut with the given name, as if the user pressed its keys. It returns
+// an unsupported error when KWin doesn't have such a shortcut
+func (k KWin) invokeKWinShortcut(name string) error {
+	names, err := k.getKWinShortcutNames()
+	if err != nil {
+		return err
+	}
+	if !slices.Contains(names, name) {
+		return fmt.Errorf("kwin shortcut %q is not supported", name)
+	}
+	output, err := k.callDbusSend(context.Background(),
+		"--print-reply",
+		"--dest=org.kde.kglobalaccel",
+		"/component/kwin", "org.kde.kglobalaccel.Component.invokeShortcut",
+		"string:"+name)
+	if err != nil {
+		fmt.Printf("Error invoking shortcut: %v\n", err)
+		return err
+	}
+	return checkVoidReply(output, "invokeShortcut")
+}
+
+// ShowOverview opens (or closes, when it is open already) the KWin overview, which shows the windows of the current
+// desktop side by side and the other desktops, by triggering its global shortcut. An unsupported error is returned when
+// the overview effect is not loaded, e.g. because it is disabled in the settings or the compositing is off
+func (k KWin) ShowOverview() error {
+	effects, err := k.GetLoadedEffects()
+	if err != nil {
+		return err
+	}
+	if !slices.Contains(effects, overviewEffect) {
+		return fmt.Errorf("overview is not supported: the %s effect is not loaded", overviewEffect)
+	}
+	return k.invokeKWinShortcut(overviewShortcut)
+}
+
+// ShowWindowSwitcher opens the KWin window switcher (task switcher), the same as pressing Alt+Tab, by triggering its
+// global shortcut. The switcher stays open only as long as its keys are held, so without them it may close right away
+// and activate the next window, depending on the switcher settings. An unsupported error is returned when KWin has no
+// such shortcut
+func (k KWin) ShowWindowSwitcher() error {
+	return k.invokeKWinShortcut(windowSwitcherShortcut)
+}
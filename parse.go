@@ -0,0 +1,72 @@
+package go_kwin6
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// scriptLine removes the "js: " prefix, which KWin adds to the script output lines in the journal, and the surrounding
+// whitespace, so an empty print leaves an empty line
+func scriptLine(s string) string {
+	return strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(s), "js:"))
+}
+
+// parseScreenLine converts a single line of script output, as printed by the GetScreens script, into a Screen. Screen
+// properties which KWin doesn't know (e.g. the serial number of some monitors) are printed as empty strings or 0
+func parseScreenLine(s string) (Screen, error) {
+	d := Screen{}
+	if err := json.Unmarshal([]byte(scriptLine(s)), &d); err != nil {
+		return Screen{}, err
+	}
+	return d, nil
+}
+
+// parseDesktopLine converts a single line of script output, as printed by the GetDesktops script, into a Desktop. It
+// fails when the desktop id is not a valid uuid
+func parseDesktopLine(s string) (Desktop, error) {
+	d := Desktop{}
+	if err := json.Unmarshal([]byte(scriptLine(s)), &d); err != nil {
+		return Desktop{}, err
+	}
+	if _, err := uuid.Parse(d.Id); err != nil {
+		return Desktop{}, fmt.Errorf("invalid desktop id %q: %w", d.Id, err)
+	}
+	return d, nil
+}
+
+// parseWindowLine converts a single line of script output, produced by windowToJSON, into a Window. It only parses what
+// KWin printed and doesn't enrich the Window with process information. Invalid UTF-8 sequences, e.g. in the caption of
+// a misbehaving program, are replaced with the Unicode replacement character. It fails when the window id is not a
+// valid uuid
+func parseWindowLine(s string) (Window, error) {
+	d := Window{}
+	if err := json.Unmarshal([]byte(strings.ToValidUTF8(scriptLine(s), "\uFFFD")), &d); err != nil {
+		return Window{}, err
+	}
+	if _, err := uuid.Parse(d.Id); err != nil {
+		return Window{}, fmt.Errorf("invalid window id %q: %w", d.Id, err)
+	}
+	return d, nil
+}
+
+// scriptError returns an error holding the lines of the given script output which are not JSON objects, or nil when
+// there are none. Scripts which report objects print one JSON object per line, so any other line is the exception text
+// (with its stack trace) KWin logged when the script threw. Lines starting with "#" are the tagged output of the watch
+// scripts (see newWatchTag) or the markers of other scripts, which may run at the same time and show up in the same
+// journal time window, so they are not part of the exception text
+func scriptError(output []string) error {
+	var text []string
+	for _, s := range output {
+		line := scriptLine(s)
+		if line != "" && !strings.HasPrefix(line, "{") && !strings.HasPrefix(line, "#") {
+			text = append(text, line)
+		}
+	}
+	if len(text) == 0 {
+		return nil
+	}
+	return fmt.Errorf("script error: %s", strings.Join(text, "\n"))
+}
@@ -0,0 +1,214 @@
+package go_kwin6
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestScriptLine(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{"prefixed", `js: {"id": 1}`, `{"id": 1}`},
+		{"not prefixed", `{"id": 1}`, `{"id": 1}`},
+		{"surrounding whitespace", "  js: done\n", "done"},
+		{"prefix only once", "js: js: text", "js: text"},
+		{"empty print", "js: ", ""},
+		{"empty", "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := scriptLine(tt.in); got != tt.want {
+				t.Errorf("scriptLine(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseScreenLine(t *testing.T) {
+	tests := []struct {
+		name    string
+		in      string
+		want    Screen
+		wantErr bool
+	}{
+		{
+			name: "full",
+			in: `js: {"name": "DP-1", "manufacturer": "Dell", "model": "U2720Q", "serial": "ABC123", ` +
+				`"pixelRatio": 1.5, ` +
+				`"geometry": {"topLeft": {"x":0,"y":0}, "bottomRight": {"x":2560,"y":1440}}}`,
+			want: Screen{
+				Name: "DP-1", Manufacturer: "Dell", Model: "U2720Q", SerialNumber: "ABC123",
+				PixelRatio: 1.5, Geometry: Rect{TopLeft: Point{0, 0}, BottomRight: Point{2560, 1440}},
+			},
+		},
+		{
+			name: "missing serial",
+			in: `js: {"name": "eDP-1", "manufacturer": "BOE", "model": "0x095F", "serial": "", ` +
+				`"pixelRatio": 2, ` +
+				`"geometry": {"topLeft": {"x":0,"y":0}, "bottomRight": {"x":1280,"y":800}}}`,
+			want: Screen{
+				Name: "eDP-1", Manufacturer: "BOE", Model: "0x095F", SerialNumber: "", PixelRatio: 2,
+				Geometry: Rect{TopLeft: Point{0, 0}, BottomRight: Point{1280, 800}},
+			},
+		},
+		{
+			name: "undefined in a string",
+			in:   `js: {"name": "DP-3", "manufacturer": "undefined", "model": "Model undefined", "pixelRatio": 1}`,
+			want: Screen{Name: "DP-3", Manufacturer: "undefined", Model: "Model undefined", PixelRatio: 1},
+		},
+		{name: "bare undefined", in: `{"name": "HDMI-A-1", "pixelRatio": undefined}`, wantErr: true},
+		{name: "malformed", in: `js: {"name": "DP-1"`, wantErr: true},
+		{name: "exception text", in: `js: TypeError: screen is null`, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseScreenLine(tt.in)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseScreenLine() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseScreenLine() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseDesktopLine(t *testing.T) {
+	id := "5b1e3b2a-6f43-4a3e-9c1d-2f4e6a8b0c1d"
+	tests := []struct {
+		name    string
+		in      string
+		want    Desktop
+		wantErr bool
+	}{
+		{
+			name: "full",
+			in:   `js: {"id": "` + id + `", "index": 2, "name": "Mail", "x11Number": 3}`,
+			want: Desktop{Id: id, Index: 2, Name: "Mail", X11Number: 3},
+		},
+		{
+			name: "escaped name",
+			in:   `{"id": "` + id + `", "index": 0, "name": "\"Work\" \\ 1", "x11Number": 1}`,
+			want: Desktop{Id: id, Name: `"Work" \ 1`, X11Number: 1},
+		},
+		{name: "malformed id", in: `js: {"id": "not-a-uuid", "index": 0, "name": "Desktop 1"}`, wantErr: true},
+		{name: "missing id", in: `js: {"index": 0, "name": "Desktop 1"}`, wantErr: true},
+		{name: "malformed", in: `js: {"id": "` + id + `",`, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseDesktopLine(tt.in)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseDesktopLine() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseDesktopLine() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseWindowLine(t *testing.T) {
+	id := "0f6d1c3e-8a2b-4d5f-9e7a-1b3c5d7e9f02"
+	desktopId := uuid.MustParse("5b1e3b2a-6f43-4a3e-9c1d-2f4e6a8b0c1d")
+	tests := []struct {
+		name    string
+		in      string
+		want    Window
+		wantErr bool
+	}{
+		{
+			name: "full",
+			in: `js: {"id": "` + id + `", "caption": "Inbox - Mail", "pid": 4242, "resourceClass": "org.kde.kmail2", ` +
+				`"x": 10, "y": 20.5, "width": 800, "height": 600, "minimized": true, ` +
+				`"desktopIds": ["` + desktopId.String() + `"]}`,
+			want: Window{
+				Id: id, Caption: "Inbox - Mail", Pid: 4242, ResourceClass: "org.kde.kmail2",
+				X: 10, Y: 20.5, Width: 800, Height: 600, Minimized: true,
+				DesktopIds: []uuid.UUID{desktopId},
+			},
+		},
+		{
+			name: "quotes and special characters in caption",
+			in:   `js: {"id": "` + id + `", "caption": "\"main.go\" \\ {draft}\té — 100% <b>"}`,
+			want: Window{Id: id, Caption: "\"main.go\" \\ {draft}\té — 100% <b>"},
+		},
+		{
+			name: "invalid UTF-8 in caption",
+			in:   "js: {\"id\": \"" + id + "\", \"caption\": \"bad \xff\xfe bytes\"}",
+			want: Window{Id: id, Caption: "bad � bytes"},
+		},
+		{
+			name: "empty cmdline",
+			in:   `js: {"id": "` + id + `", "pid": 0, "cmdline": ""}`,
+			want: Window{Id: id},
+		},
+		{
+			name:    "malformed uuid",
+			in:      `js: {"id": "` + strings.Replace(id, "0f", "zz", 1) + `", "caption": "x"}`,
+			wantErr: true,
+		},
+		{name: "truncated uuid", in: `js: {"id": "` + id[:20] + `"}`, wantErr: true},
+		{name: "malformed", in: `js: {"id": "` + id + `", "caption": }`, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseWindowLine(tt.in)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseWindowLine() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseWindowLine() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestScriptError(t *testing.T) {
+	tests := []struct {
+		name    string
+		output  []string
+		wantErr string
+	}{
+		{name: "no output", output: nil},
+		{name: "objects only", output: []string{`js: {"id": 1}`, `{"id": 2}`}},
+		{name: "empty lines", output: []string{"", "js: ", `js: {"id": 1}`}},
+		{
+			name: "other scripts",
+			output: []string{`js: #0f6d1c3e-8a2b-4d5f-9e7a-1b3c5d7e9f02#{"type": "added"}`, "js: #end-42",
+				`js: {"id": 1}`},
+		},
+		{
+			name:    "exception",
+			output:  []string{`js: {"id": 1}`, "js: TypeError: Cannot read property 'name' of null", "js: at line 12"},
+			wantErr: "script error: TypeError: Cannot read property 'name' of null\nat line 12",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := scriptError(tt.output)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("scriptError() = %v, want nil", err)
+				}
+				return
+			}
+			if err == nil || err.Error() != tt.wantErr {
+				t.Fatalf("scriptError() = %v, want %q", err, tt.wantErr)
+			}
+			if errors.Is(err, ErrNoScriptOutput) {
+				t.Errorf("scriptError() = %v, must not wrap ErrNoScriptOutput", err)
+			}
+			if strings.Contains(err.Error(), "js: ") {
+				t.Errorf("scriptError() = %v, must strip the js: prefix", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,173 @@
+package go_kwin6
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// getProcessParents uses the linux /proc infrastructure to build a map of all running processes, where the key is the
+// process PID and the value is the PID of its parent process
+func (k KWin) getProcessParents() (map[int]int, error) {
+	if k.NoProcLookup {
+		return nil, fmt.Errorf("process lookup is disabled")
+	}
+	entries, err := os.ReadDir("/proc")
+	if err != nil {
+		fmt.Printf("Error reading processes: %v\n", err)
+		return nil, err
+	}
+	parents := make(map[int]int, len(entries))
+	for _, e := range entries {
+		pid, err := strconv.Atoi(e.Name())
+		if err != nil {
+			continue
+		}
+		stat, err := os.ReadFile(fmt.Sprintf("/proc/%d/stat", pid))
+		if err != nil {
+			// the process exited in the meantime
+			continue
+		}
+		// the process name, in parentheses, may contain spaces, so the fields are counted from its end:
+		// pid (comm) state ppid ...
+		s := string(stat)
+		fields := strings.Fields(s[strings.LastIndexByte(s, ')')+1:])
+		if len(fields) < 2 {
+			continue
+		}
+		ppid, err := strconv.Atoi(fields[1])
+		if err != nil {
+			continue
+		}
+		parents[pid] = ppid
+	}
+	return parents, nil
+}
+
+// isDescendant reports whether the process pid is the process rootPid itself or any of its descendants, according to the
+// given pid to parent pid map
+func isDescendant(parents map[int]int, pid, rootPid int) bool {
+	for pid > 0 {
+		if pid == rootPid {
+			return true
+		}
+		ppid, ok := parents[pid]
+		if !ok || ppid == pid {
+			return false
+		}
+		pid = ppid
+	}
+	return false
+}
+
+// MatchWindowByProcessTree returns the windows which belong to the process rootPid or to any of its descendant
+// processes, sorted by window id. Many programs (browsers, Electron applications) own their windows by a child process,
+// which is different from the process that was started
+func (k KWin) MatchWindowByProcessTree(rootPid int) ([]Window, error) {
+	windows, err := k.GetWindows(nil)
+	if err != nil {
+		fmt.Printf("Error getting windows: %v\n", err)
+		return nil, err
+	}
+	parents, err := k.getProcessParents()
+	if err != nil {
+		return nil, err
+	}
+	matching := make([]Window, 0)
+	for _, w := range windows {
+		if isDescendant(parents, w.Pid, rootPid) {
+			matching = append(matching, w)
+		}
+	}
+	sortWindows(matching)
+	return matching, nil
+}
+
+// GetWindowsByPID returns the windows, except the special ones, owned by the process with the given PID, sorted by
+// window id. The pid is matched inside the script, so the other windows are neither reported nor enriched with process
+// information
+func (k KWin) GetWindowsByPID(pid int) ([]Window, error) {
+	if pid <= 0 {
+		return nil, fmt.Errorf("invalid pid: %d", pid)
+	}
+	windows, err := k.GetWindowsFiltered(nil, WindowFilter{Pid: pid})
+	if err != nil {
+		return nil, err
+	}
+	ws := make([]Window, 0, len(windows))
+	for _, w := range windows {
+		ws = append(ws, w)
+	}
+	sortWindows(ws)
+	return ws, nil
+}
+
+// MoveWindowsByPIDToDesktop will attempt to move all windows of the process with the given PID to a given Desktop,
+// like MoveWindowsToDesktop. It returns the windows which could not be moved
+func (k KWin) MoveWindowsByPIDToDesktop(pid int, d Desktop) ([]Window, error) {
+	ws, err := k.GetWindowsByPID(pid)
+	if err != nil {
+		return nil, err
+	}
+	return k.MoveWindowsToDesktop(ws, d)
+}
+
+// MoveWindowsByPIDToScreen will attempt to move all windows of the process with the given PID to a given Screen
+// output, like MoveWindowsToScreen. It returns the windows which could not be moved
+func (k KWin) MoveWindowsByPIDToScreen(pid int, s Screen) ([]Window, error) {
+	ws, err := k.GetWindowsByPID(pid)
+	if err != nil {
+		return nil, err
+	}
+	return k.MoveWindowsToScreen(ws, s)
+}
+
+// procReadWorkers is the maximum number of /proc command lines read at the same time by getProcessCmdLines
+const procReadWorkers = 8
+
+// processCmdLine is the command line of a process, or the error reading it, as read by getProcessCmdLines
+type processCmdLine struct {
+	cmdLine string
+	err     error
+}
+
+// getProcessCmdLines reads the command lines of the given processes concurrently, by a bounded pool of workers, and
+// returns them keyed by PID. A failure to read one process doesn't affect the others, it is kept with its PID.
+// Duplicate and non-positive PIDs are skipped, and nothing is read when NoProcLookup is set
+func (k KWin) getProcessCmdLines(pids []int) map[int]processCmdLine {
+	cmdLines := make(map[int]processCmdLine, len(pids))
+	if k.NoProcLookup {
+		return cmdLines
+	}
+	unique := make([]int, 0, len(pids))
+	seen := make(map[int]bool, len(pids))
+	for _, pid := range pids {
+		if pid > 0 && !seen[pid] {
+			seen[pid] = true
+			unique = append(unique, pid)
+		}
+	}
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	jobs := make(chan int)
+	for i := 0; i < min(procReadWorkers, len(unique)); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for pid := range jobs {
+				cmdLine, err := k.getProcessCmdLine(pid)
+				mu.Lock()
+				cmdLines[pid] = processCmdLine{cmdLine: cmdLine, err: err}
+				mu.Unlock()
+			}
+		}()
+	}
+	for _, pid := range unique {
+		jobs <- pid
+	}
+	close(jobs)
+	wg.Wait()
+	return cmdLines
+}
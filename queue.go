@@ -0,0 +1,83 @@
+package go_kwin6
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Queued returns a copy of the KWin object which, instead of executing the scripts of the void mutators (the mutating
+// methods which report nothing back, like MoveWindowToDesktop, MaximizeWindow or MinimizeWindow) right away, queues
+// them until Flush is called (on either copy), when all queued scripts are combined into a single script and executed
+// together. This amortizes the load/run/stop/journal cost of each script across many operations.
+//
+//	NOTE: Methods which read anything, or check the outcome of a change (e.g. MoveWindowToOutput or the *Result
+//	methods), still run their script immediately, so they see the state before the queued changes are flushed
+func (k KWin) Queued() KWin {
+	k.queued = true
+	return k
+}
+
+// runMutation executes the given script of a void mutator, whose output is only printed, or queues it when the KWin
+// object is Queued
+func (k KWin) runMutation(ctx context.Context, script string) error {
+	if k.queued {
+		return k.QueueScript(script)
+	}
+	output, err := k.loadExecuteAndGetOutputContext(ctx, script)
+	for _, s := range output {
+		fmt.Println(s)
+	}
+	return err
+}
+
+// QueueScript adds the given JavaScript code to the queue of scripts to be executed by the next Flush. If
+// AutoFlushInterval is set and the queue was empty, a Flush is scheduled after the interval
+func (k KWin) QueueScript(script string) error {
+	if k.state == nil {
+		return fmt.Errorf("script queue is not available, create the KWin object with NewKWin")
+	}
+	k.state.mu.Lock()
+	defer k.state.mu.Unlock()
+	k.state.queue = append(k.state.queue, script)
+	if k.AutoFlushInterval > 0 && k.state.flushTimer == nil {
+		k.state.flushTimer = time.AfterFunc(k.AutoFlushInterval, func() {
+			if err := k.Flush(); err != nil {
+				fmt.Printf("Error flushing queued scripts: %v\n", err)
+			}
+		})
+	}
+	return nil
+}
+
+// Flush executes all queued scripts, in the order they were queued, as a single combined script. Each queued script runs
+// in its own function scope and an exception thrown by one of them doesn't prevent the following ones from running
+func (k KWin) Flush() error {
+	if k.state == nil {
+		return nil
+	}
+	k.state.mu.Lock()
+	queue := k.state.queue
+	k.state.queue = nil
+	if k.state.flushTimer != nil {
+		k.state.flushTimer.Stop()
+		k.state.flushTimer = nil
+	}
+	k.state.mu.Unlock()
+	if len(queue) == 0 {
+		return nil
+	}
+
+	var script strings.Builder
+	for _, s := range queue {
+		script.WriteString("\n(function() {\n    try {")
+		script.WriteString(s)
+		script.WriteString("\n    } catch (e) {\n        print(\"Queued script failed: \"+e);\n    }\n})();\n")
+	}
+	output, err := k.loadExecuteAndGetOutput(script.String())
+	for _, s := range output {
+		fmt.Println(s)
+	}
+	return err
+}
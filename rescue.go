@@ -0,0 +1,75 @@
+package go_kwin6
+
+import (
+	"fmt"
+	"math"
+)
+
+// intersects reports whether two rectangles, with exclusive BottomRight points, overlap
+func intersects(a, b Rect) bool {
+	return a.TopLeft.X < b.BottomRight.X && b.TopLeft.X < a.BottomRight.X &&
+		a.TopLeft.Y < b.BottomRight.Y && b.TopLeft.Y < a.BottomRight.Y
+}
+
+// FindOffscreenWindows returns the windows of the Environment which can't be seen on any Screen, because their
+// geometry doesn't intersect any Screen or they have a zero size, sorted by window id. This happens e.g. after a
+// monitor is disconnected. Minimized windows are not reported, as their geometry is not meaningful
+func FindOffscreenWindows(env Environment) ([]Window, error) {
+	if len(env.Screens) == 0 {
+		return nil, fmt.Errorf("environment has no screens")
+	}
+	offscreen := make([]Window, 0)
+	for _, w := range env.Windows {
+		if w.Minimized {
+			continue
+		}
+		g := w.Geometry()
+		visible := false
+		if g.BottomRight.X > g.TopLeft.X && g.BottomRight.Y > g.TopLeft.Y {
+			for _, s := range env.Screens {
+				if intersects(g, s.Geometry) {
+					visible = true
+					break
+				}
+			}
+		}
+		if !visible {
+			offscreen = append(offscreen, w)
+		}
+	}
+	sortWindows(offscreen)
+	return offscreen, nil
+}
+
+// RescueWindow will attempt to move a given Window to the center of the primary Screen. A Window which is larger than
+// the Screen, or has a zero size, is resized to half of the Screen size. The primary Screen is read from KScreen, when
+// it is not available the first Screen is used
+func (k KWin) RescueWindow(w Window) error {
+	screens, err := k.GetAllOutputs()
+	if err != nil {
+		fmt.Printf("Error getting outputs, falling back to the first screen: %v\n", err)
+		if screens, err = k.GetScreens(); err != nil {
+			fmt.Printf("Error getting screens: %v\n", err)
+			return err
+		}
+	}
+	var target *Screen
+	for _, s := range screens {
+		if !s.Enabled {
+			continue
+		}
+		if s.Primary || target == nil || !target.Primary && s.Index < target.Index {
+			target = &s
+		}
+	}
+	if target == nil {
+		return fmt.Errorf("no screen to rescue window %s to", w.Id)
+	}
+	screenWidth := float64(target.Geometry.BottomRight.X - target.Geometry.TopLeft.X)
+	screenHeight := float64(target.Geometry.BottomRight.Y - target.Geometry.TopLeft.Y)
+	if w.Width <= 0 || w.Height <= 0 || w.Width > screenWidth || w.Height > screenHeight {
+		w.Width = math.Round(screenWidth / 2)
+		w.Height = math.Round(screenHeight / 2)
+	}
+	return k.SetWindowGeometry(w, w.CenteredGeometry(*target))
+}
@@ -0,0 +1,168 @@
+package go_kwin6
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type (
+	// AssignmentRule is a struct that describes where the windows selected by the Filter belong. Every non-nil target is
+	// applied, the nil ones are left as they are
+	AssignmentRule struct {
+		// Filter selects the windows the rule applies to
+		Filter WindowFilter `json:"filter"`
+		// Desktop is the Desktop the windows are moved to
+		Desktop *Desktop `json:"desktop,omitempty"`
+		// Screen is the Screen output the windows are moved to
+		Screen *Screen `json:"screen,omitempty"`
+		// Geometry is the geometry, in logical pixels, the windows are moved and resized to
+		Geometry *Rect `json:"geometry,omitempty"`
+	}
+	// AssignmentRules is an ordered list of AssignmentRule's, where the first rule matching a Window wins
+	AssignmentRules []AssignmentRule
+	// RuleMatch is a struct that reports which rule, by its index in the AssignmentRules, matched a Window
+	RuleMatch struct {
+		Window Window `json:"window"`
+		Rule   int    `json:"rule"`
+	}
+)
+
+// Match returns the index of the first rule which matches the given Window, or -1 if no rule matches
+func (rs AssignmentRules) Match(w Window) int {
+	for i, r := range rs {
+		if r.Filter.Matches(w) {
+			return i
+		}
+	}
+	return -1
+}
+
+// ApplyRules will attempt to move every Window of the given Environment, which is matched by one of the rules, to the
+// targets of the first matching rule, and returns the matches sorted by window id. A failure to apply a rule to one
+// Window doesn't stop the others, all failures are returned together as the error
+func (k KWin) ApplyRules(rules AssignmentRules, env Environment) ([]RuleMatch, error) {
+	windows := make([]Window, 0, len(env.Windows))
+	for _, w := range env.Windows {
+		windows = append(windows, w)
+	}
+	sortWindows(windows)
+	matches := make([]RuleMatch, 0)
+	var errs []error
+	for _, w := range windows {
+		i := rules.Match(w)
+		if i < 0 {
+			continue
+		}
+		matches = append(matches, RuleMatch{Window: w, Rule: i})
+		if err := k.applyRule(rules[i], w); err != nil {
+			fmt.Printf("Error applying rule %d to window %s: %v\n", i, w.Id, err)
+			errs = append(errs, fmt.Errorf("rule %d, window %s: %w", i, w.Id, err))
+		}
+	}
+	return matches, errors.Join(errs...)
+}
+
+// applyRule moves the given Window to the targets of the given rule. A window which already has the geometry of the
+// rule is not resized again, so reconciling doesn't keep re-applying the same geometry
+func (k KWin) applyRule(r AssignmentRule, w Window) error {
+	if r.Desktop != nil {
+		if err := k.EnsureWindowOnDesktops(w, []Desktop{*r.Desktop}); err != nil {
+			return err
+		}
+	}
+	if r.Screen != nil {
+		if err := k.MoveWindowToScreen(w, *r.Screen); err != nil {
+			return err
+		}
+	}
+	if r.Geometry != nil && !w.hasGeometry(*r.Geometry) {
+		if err := k.SetWindowGeometry(w, *r.Geometry); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ReconcileLoop applies the given rules to the windows as they appear, until ctx is cancelled, so newly launched
+// programs land where the rules say. Only the windows created after the loop started are touched, the existing ones
+// and the windows the user moved afterwards are left alone. With a positive interval the windows are polled every
+// interval and compared to the previous poll, starting with a baseline snapshot taken when the loop starts, otherwise
+// they are watched with WatchWindows. Failures to apply a rule are printed and don't stop the loop. The returned error
+// is the ctx error, the error starting the watch or taking the baseline, or an error when the watch ended on its own
+// (e.g. because journalctl or dbus-monitor died)
+func (k KWin) ReconcileLoop(ctx context.Context, rules AssignmentRules, interval time.Duration) error {
+	if interval > 0 {
+		return k.reconcilePolling(ctx, rules, interval)
+	}
+	events, err := k.WatchWindows(ctx)
+	if err != nil {
+		fmt.Printf("Error watching windows: %v\n", err)
+		return err
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case e, ok := <-events:
+			if !ok {
+				if ctx.Err() != nil {
+					return ctx.Err()
+				}
+				return errors.New("window watch ended")
+			}
+			if e.Type == WindowAdded {
+				k.reconcile(rules, map[uuid.UUID]Window{uuid.MustParse(e.Window.Id): e.Window})
+			}
+		}
+	}
+}
+
+// reconcilePolling is the polling version of ReconcileLoop
+func (k KWin) reconcilePolling(ctx context.Context, rules AssignmentRules, interval time.Duration) error {
+	baseline, err := k.GetWindows(nil)
+	if err != nil {
+		fmt.Printf("Error getting windows: %v\n", err)
+		return err
+	}
+	seen := make(map[uuid.UUID]bool, len(baseline))
+	for id := range baseline {
+		seen[id] = true
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(interval):
+		}
+		windows, err := k.GetWindows(nil)
+		if err != nil {
+			fmt.Printf("Error getting windows: %v\n", err)
+			continue
+		}
+		added := make(map[uuid.UUID]Window)
+		for id, w := range windows {
+			if !seen[id] {
+				added[id] = w
+			}
+		}
+		k.reconcile(rules, added)
+		seen = make(map[uuid.UUID]bool, len(windows))
+		for id := range windows {
+			seen[id] = true
+		}
+	}
+}
+
+// reconcile applies the given rules to the given windows, printing the failures
+func (k KWin) reconcile(rules AssignmentRules, windows map[uuid.UUID]Window) {
+	if len(windows) == 0 {
+		return
+	}
+	if _, err := k.ApplyRules(rules, Environment{Windows: windows}); err != nil {
+		fmt.Printf("Error reconciling windows: %v\n", err)
+	}
+}
@@ -0,0 +1,36 @@
+package go_kwin6
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// ErrNotKWin is returned (wrapped) by CheckDesktopEnvironment when the session runs another desktop environment or
+// compositor than KDE Plasma with KWin
+var ErrNotKWin = errors.New("this package requires KWin")
+
+// CheckDesktopEnvironment is a cheap check, which doesn't call KWin, whether the session is a KDE Plasma session. It
+// reads the XDG_CURRENT_DESKTOP variable (a colon separated list, e.g. "KDE") and, when it is not set, the
+// XDG_SESSION_DESKTOP and DESKTOP_SESSION variables. On another desktop environment (GNOME, Sway etc.) all calls to
+// org.kde.KWin would fail with cryptic dbus errors, so an error wrapping ErrNotKWin, naming the detected environment, is
+// returned instead. When none of the variables is set the environment can't be told and nil is returned
+func CheckDesktopEnvironment() error {
+	detected := ""
+	for _, name := range []string{"XDG_CURRENT_DESKTOP", "XDG_SESSION_DESKTOP", "DESKTOP_SESSION"} {
+		if detected = os.Getenv(name); detected != "" {
+			break
+		}
+	}
+	if detected == "" {
+		return nil
+	}
+	for _, de := range strings.Split(detected, ":") {
+		de = strings.ToLower(strings.TrimSpace(de))
+		if de == "kde" || strings.HasPrefix(de, "plasma") {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w; detected %s", ErrNotKWin, detected)
+}
@@ -0,0 +1,104 @@
+package go_kwin6
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// ScriptAction is the body of a JavaScript function, run by KWin when a registered shortcut is pressed. It can use the
+// whole KWin scripting API, e.g. workspace.activeWindow
+type ScriptAction string
+
+const (
+	// ActionMaximizeActiveWindow toggles the maximized state of the active window
+	ActionMaximizeActiveWindow ScriptAction = `
+        var window = workspace.activeWindow;
+        if (window) {
+            var maximize = windowMaximizeMode(window) !== 3;
+            window.setMaximize(maximize, maximize);
+        }`
+	// ActionCenterActiveWindow moves the active window to the center of its screen
+	ActionCenterActiveWindow ScriptAction = `
+        var window = workspace.activeWindow;
+        if (window && window.moveable && window.output) {
+            var area = window.output.geometry;
+            var g = window.frameGeometry;
+            window.frameGeometry = {
+                x: Math.round(area.x + (area.width - g.width) / 2),
+                y: Math.round(area.y + (area.height - g.height) / 2),
+                width: g.width,
+                height: g.height
+            };
+        }`
+	// ActionMoveActiveWindowToNextScreen sends the active window to the next screen, wrapping around
+	ActionMoveActiveWindowToNextScreen ScriptAction = `
+        var window = workspace.activeWindow;
+        if (window && window.moveable && window.output) {
+            var screens = workspace.screens;
+            for (var i = 0; i < screens.length; i++) {
+                if (screens[i].name === window.output.name) {
+                    workspace.sendClientToScreen(window, screens[(i + 1) % screens.length]);
+                    break;
+                }
+            }
+        }`
+)
+
+// shortcutPluginName returns the plugin name the script of the shortcut with the given name is registered under
+func shortcutPluginName(name string) string {
+	return "gokwin6_shortcut_" + strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' {
+			return r
+		}
+		return '_'
+	}, name)
+}
+
+// RegisterShortcut will attempt to register a global shortcut with the given name and default key sequence (e.g.
+// "Meta+Ctrl+M"), which runs the given action when pressed. The shortcut is backed by a script which stays loaded in
+// KWin, also after this program exits or the KWin object is closed, until UnregisterShortcut is called or KWin
+// restarts. Registering a name again replaces the previous shortcut. The key sequence can be changed by the user in the
+// System Settings, where the shortcut is listed under its name
+func (k KWin) RegisterShortcut(name, keySequence string, action ScriptAction) error {
+	script := windowMaximizeMode + `
+    registerShortcut("%s", "%s", "%s", function() {%s
+    });`
+	if name == "" {
+		return fmt.Errorf("shortcut has no name")
+	}
+	if err := k.UnregisterShortcut(name); err != nil {
+		return err
+	}
+	scriptFile, err := k.createScriptFile(fmt.Sprintf(script,
+		escapeJSString(name), escapeJSString(name), escapeJSString(keySequence), action))
+	if err != nil {
+		return err
+	}
+	defer k.removeScriptFile(scriptFile)
+
+	scriptNo, err := k.loadNamedScript(context.Background(), scriptFile.Name(), shortcutPluginName(name))
+	if err != nil {
+		fmt.Printf("Error loading shortcut script: %v\n", err)
+		return err
+	}
+	// The script must outlive the KWin object, so Close must not stop it
+	k.trackScript(scriptNo, false)
+	err = k.runScript(context.Background(), scriptNo)
+	if err != nil {
+		fmt.Printf("Error running shortcut script: %v\n", err)
+		_ = k.stopScript(context.Background(), scriptNo)
+		return err
+	}
+	return nil
+}
+
+// UnregisterShortcut will attempt to unload the script of the shortcut registered with the given name, which removes
+// the shortcut. Unregistering a name which is not registered does nothing
+func (k KWin) UnregisterShortcut(name string) error {
+	_, err := k.callDbusSend(context.Background(),
+		"--print-reply",
+		"--dest=org.kde.KWin",
+		"/Scripting", "org.kde.kwin.Scripting.unloadScript", "string:"+shortcutPluginName(name))
+	return err
+}
@@ -0,0 +1,130 @@
+package go_kwin6
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"os/exec"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// KWinSignal is a struct that describes a dbus signal emitted by KWin, as reported by WatchKWinSignals. The Args are
+// the signal arguments as printed by dbus-monitor, with the type removed for basic types, e.g. the desktop id for the
+// currentChanged signal of the org.kde.KWin.VirtualDesktopManager interface
+type KWinSignal struct {
+	Path      string   `json:"path"`
+	Interface string   `json:"interface"`
+	Member    string   `json:"member"`
+	Args      []string `json:"args"`
+}
+
+// signalHeaderPattern matches the first line dbus-monitor prints for a signal
+var signalHeaderPattern = regexp.MustCompile(`^signal .* path=([^;]+); interface=([^;]+); member=(\S+)`)
+
+// WatchKWinSignals returns a channel which receives the dbus signals emitted by KWin, e.g. the desktop changes of the
+// /VirtualDesktopManager object. The signals are read from a dbus-monitor subprocess, so unlike the other watches this
+// doesn't run a script and doesn't depend on the journal or the logging rules, but only coarse events are available.
+// Should dbus-monitor exit prematurely, it is restarted. When ctx is cancelled dbus-monitor is killed and the channel
+// is closed
+func (k KWin) WatchKWinSignals(ctx context.Context) (<-chan KWinSignal, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	release, err := k.trackWatch(cancel)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	startSource := func() (*exec.Cmd, io.Reader, error) {
+		cmd := exec.CommandContext(ctx, dbusMonitor, "--session", "type='signal',sender='org.kde.KWin'")
+		stdout, err := cmd.StdoutPipe()
+		if err != nil {
+			return nil, nil, err
+		}
+		return cmd, stdout, cmd.Start()
+	}
+	cmd, stdout, err := startSource()
+	if err != nil {
+		fmt.Printf("Error starting dbus monitor: %v\n", err)
+		cancel()
+		release()
+		return nil, err
+	}
+
+	signals := make(chan KWinSignal)
+	go func() {
+		defer func() {
+			close(signals)
+			cancel()
+			release()
+		}()
+		for {
+			k.readKWinSignals(ctx, stdout, signals)
+			_ = cmd.Wait()
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(watchRestartDelay):
+			}
+			fmt.Printf("Signal event source exited, restarting\n")
+			cmd, stdout, err = startSource()
+			if err != nil {
+				fmt.Printf("Error restarting dbus monitor: %v\n", err)
+				return
+			}
+		}
+	}()
+	return signals, nil
+}
+
+// readKWinSignals parses the dbus-monitor output read from r into KWinSignal's and sends them to signals, until r is
+// exhausted or ctx is done. dbus-monitor writes every message at once, so a signal is complete when the next signal
+// starts or when no more output is buffered
+func (k KWin) readKWinSignals(ctx context.Context, r io.Reader, signals chan<- KWinSignal) {
+	reader := bufio.NewReader(r)
+	var pending *KWinSignal
+	send := func() bool {
+		if pending == nil {
+			return true
+		}
+		select {
+		case signals <- *pending:
+			pending = nil
+			return true
+		case <-ctx.Done():
+			return false
+		}
+	}
+	for {
+		line, err := reader.ReadString('\n')
+		if line != "" {
+			if m := signalHeaderPattern.FindStringSubmatch(line); m != nil {
+				if !send() {
+					return
+				}
+				pending = &KWinSignal{Path: m[1], Interface: m[2], Member: m[3], Args: make([]string, 0)}
+			} else if pending != nil && strings.TrimSpace(line) != "" {
+				pending.Args = append(pending.Args, dbusArgValue(line))
+			}
+		}
+		if err != nil || reader.Buffered() == 0 {
+			if !send() || err != nil {
+				return
+			}
+		}
+	}
+}
+
+// dbusArgValue returns the value of a basic typed argument line printed by dbus-monitor (e.g. `string "abc"` or
+// `uint32 3`), or the trimmed line for the other types
+func dbusArgValue(line string) string {
+	if v, ok := dbusStringValue(line); ok {
+		return v
+	}
+	fields := strings.Fields(line)
+	if len(fields) == 2 {
+		return fields[1]
+	}
+	return strings.TrimSpace(line)
+}
@@ -0,0 +1,120 @@
+package go_kwin6
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// The combined environment stream is the output of a single script which reports the whole Environment. Every line is
+// one record, made of a type discriminator, a space and the JSON representation of the record:
+//
+//	screen {...Screen...}
+//	desktop {...Desktop...}
+//	window {...Window...}
+//	active {"activeWindowId": ..., "currentDesktopId": ..., "activeScreen": ...}
+//
+// Lines may carry the "js: " prefix KWin adds in the journal
+
+// environmentStreamScript prints the combined environment stream
+const environmentStreamScript = screenToJSON + desktopToJSON + windowToJSON + activeStateToJSON + `
+	for (var i = 0; i < workspace.screens.length; i++) {
+		print("screen "+screenToJSON(i))
+	}
+	for (var i = 0; i < workspace.desktops.length; i++) {
+		print("desktop "+desktopToJSON(i))
+	}
+	for (const window of workspace.windowList()) {
+		if (window.specialWindow) {
+			continue;
+		}
+		print("window "+windowToJSON(window))
+	}
+	print("active "+activeStateToJSON())`
+
+// DecodeEnvironmentStream reads the lines of a combined environment stream from r and assembles the Environment. Empty
+// lines are skipped and an unknown record type is an error. The windows only carry what the script reported, i.e. their
+// process command line, application name and Desktop objects are not filled in
+func DecodeEnvironmentStream(r io.Reader) (Environment, error) {
+	env := Environment{
+		Screens:  make(map[string]Screen),
+		Desktops: make(map[uuid.UUID]Desktop),
+		Windows:  make(map[uuid.UUID]Window),
+	}
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := scriptLine(scanner.Text())
+		if line == "" {
+			continue
+		}
+		kind, record, _ := strings.Cut(line, " ")
+		switch kind {
+		case "screen":
+			s, err := parseScreenLine(record)
+			if err != nil {
+				return Environment{}, err
+			}
+			env.Screens[s.Name] = s
+		case "desktop":
+			d, err := parseDesktopLine(record)
+			if err != nil {
+				return Environment{}, err
+			}
+			env.Desktops[uuid.MustParse(d.Id)] = d
+		case "window":
+			w, err := parseWindowLine(record)
+			if err != nil {
+				return Environment{}, err
+			}
+			env.Windows[uuid.MustParse(w.Id)] = w
+		case "active":
+			if err := json.Unmarshal([]byte(record), &env); err != nil {
+				return Environment{}, err
+			}
+		default:
+			return Environment{}, fmt.Errorf("unknown environment stream record: %q", kind)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return Environment{}, err
+	}
+	return env, nil
+}
+
+// GetEnvironmentSingleScript gathers the same information as GetEnvironment, but in a single script run, so the
+// Screen, Desktop and Window objects are a consistent snapshot of the same moment and the capture is faster
+func (k KWin) GetEnvironmentSingleScript() (Environment, error) {
+	output, err := k.loadExecuteAndGetOutput(environmentStreamScript)
+	if err != nil {
+		fmt.Printf("Error running script for environment: %v\n", err)
+		return Environment{}, err
+	}
+	if len(output) == 0 {
+		return Environment{}, fmt.Errorf("no environment reported: %w", ErrNoScriptOutput)
+	}
+	env, err := DecodeEnvironmentStream(strings.NewReader(strings.Join(output, "\n")))
+	if err != nil {
+		fmt.Printf("Error decoding environment: %v\n", err)
+		return Environment{}, err
+	}
+	pids := make([]int, 0, len(env.Windows))
+	for _, w := range env.Windows {
+		pids = append(pids, w.Pid)
+	}
+	cmdLines := k.getProcessCmdLines(pids)
+	for id, w := range env.Windows {
+		w, err := k.enrichWindow(w, env.Desktops, cmdLines)
+		if err != nil {
+			return Environment{}, err
+		}
+		env.Windows[id] = w
+	}
+	k.rememberGeometries(env.Windows)
+	env.countDesktopWindows()
+	return env, nil
+}
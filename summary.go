@@ -0,0 +1,55 @@
+package go_kwin6
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// WindowSummary is a struct that contains the few Window properties needed to list windows, e.g. in a pager
+type WindowSummary struct {
+	Id            string      `json:"id"`
+	Caption       string      `json:"caption"`
+	DesktopIds    []uuid.UUID `json:"desktopIds"`
+	OnAllDesktops bool        `json:"onAllDesktops"`
+	Minimized     bool        `json:"minimized"`
+}
+
+// GetWindowSummaries returns a WindowSummary for every Window, except the special ones, in the order KWin lists them.
+// The script only reads the summary properties and there are no /proc lookups, so this is much cheaper than GetWindows
+// when only a label and a location are needed
+func (k KWin) GetWindowSummaries() ([]WindowSummary, error) {
+	script := `
+	for (const window of workspace.windowList()) {
+		if (window.specialWindow) {
+			continue;
+		}
+		var out = "{"
+		out += "\"id\": \""+window.internalId.toString().replace(/{/, "").replace(/}/, "")+"\","
+		out += "\"caption\": "+JSON.stringify(window.caption)+","
+		out += "\"desktopIds\": "+JSON.stringify(window.desktops.map(function(d) { return d.id; }))+","
+		out += "\"onAllDesktops\": "+window.onAllDesktops+","
+		out += "\"minimized\": "+window.minimized
+		out += "}"
+		print(out)
+	}`
+	output, err := k.loadExecuteAndGetOutput(script)
+	if err != nil {
+		fmt.Printf("Error running script for window summaries: %v\n", err)
+		return nil, err
+	}
+	if err := scriptError(output); err != nil {
+		return nil, err
+	}
+	summaries := make([]WindowSummary, 0, len(output))
+	for _, s := range output {
+		ws := WindowSummary{}
+		if err := json.Unmarshal([]byte(strings.ToValidUTF8(scriptLine(s), "\uFFFD")), &ws); err != nil {
+			return nil, err
+		}
+		summaries = append(summaries, ws)
+	}
+	return summaries, nil
+}
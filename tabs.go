@@ -0,0 +1,105 @@
+package go_kwin6
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// Window tabbing was removed from KWin before version 6, so the methods in this file feature detect the old tabbing
+// API (tabBehind and untab of the window object) in the running KWin and return an unsupported error without it. They
+// are kept for KWin builds which bring the tabbing back
+
+// GroupWindows will attempt to group the given windows into a single tabbed container, behind the first Window found.
+// Windows which are not found (e.g. they were closed in the meantime) are reported in the error, and nothing is grouped
+// when fewer than two of the windows are found
+func (k KWin) GroupWindows(ws []Window) error {
+	script := `
+    windowIds = %s;
+    var windows = [];
+    for (const id of windowIds) {
+        var found = false;
+        for (const window of workspace.windowList()) {
+            wid = window.internalId.toString().replace(/{/, "").replace(/}/, "");
+            if (wid === id) {
+                windows.push(window);
+                found = true;
+                break;
+            }
+        }
+        if (!found) {
+            print("missing:"+id);
+        }
+    }
+    if (windows.length >= 2) {
+        if (typeof windows[0].tabBehind !== "function") {
+            print("%s");
+        } else {
+            for (var i = 1; i < windows.length; i++) {
+                windows[i].tabBehind(windows[0], false);
+            }
+        }
+    }`
+	if len(ws) < 2 {
+		return fmt.Errorf("at least two windows are needed for a group, got %d", len(ws))
+	}
+	ids := make([]string, len(ws))
+	for i, w := range ws {
+		ids[i] = w.Id
+	}
+	windowIds, err := json.Marshal(ids)
+	if err != nil {
+		return err
+	}
+	output, err := k.loadExecuteAndGetOutput(fmt.Sprintf(script, windowIds, unsupportedMarker))
+	if err != nil {
+		return err
+	}
+	if hasMarker(output, unsupportedMarker) {
+		return fmt.Errorf("window tabbing is not supported by this KWin version")
+	}
+	missing := make([]string, 0)
+	other := make([]string, 0)
+	for _, s := range output {
+		if line := scriptLine(s); strings.HasPrefix(line, "missing:") {
+			missing = append(missing, strings.TrimPrefix(line, "missing:"))
+		} else {
+			other = append(other, s)
+		}
+	}
+	if err := scriptError(other); err != nil {
+		return err
+	}
+	if len(ws)-len(missing) < 2 {
+		return fmt.Errorf("windows not grouped, fewer than two found, not found: %s", strings.Join(missing, ", "))
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("windows not found, the others were grouped: %s", strings.Join(missing, ", "))
+	}
+	return nil
+}
+
+// UngroupWindow will attempt to take a given Window out of its tabbed container
+func (k KWin) UngroupWindow(w Window) error {
+	script := `
+    windowId = "%s";
+    for (const window of workspace.windowList()) {
+        wid = window.internalId.toString().replace(/{/, "").replace(/}/, "");
+        if (wid === windowId) {
+            if (typeof window.untab !== "function") {
+                print("%s");
+            } else {
+                window.untab();
+            }
+            break;
+        }
+    }`
+	output, err := k.loadExecuteAndGetOutput(fmt.Sprintf(script, w.Id, unsupportedMarker))
+	if err != nil {
+		return err
+	}
+	if hasMarker(output, unsupportedMarker) {
+		return fmt.Errorf("window tabbing is not supported by this KWin version")
+	}
+	return nil
+}
@@ -0,0 +1,178 @@
+package go_kwin6
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+)
+
+type (
+	// LayoutSlot is a struct that describes a place in a LayoutTemplate, which is filled by a Window selected by the
+	// Filter. The Geometry is relative to the top left corner of the Screen with the ScreenName, so the template still
+	// works when the screens are arranged differently
+	LayoutSlot struct {
+		// Filter selects the windows which may fill the slot
+		Filter WindowFilter `json:"filter"`
+		// ScreenName is the name of the Screen output the slot is on
+		ScreenName string `json:"screenName"`
+		// Geometry is the window geometry, in logical pixels, relative to the Screen
+		Geometry Rect `json:"geometry"`
+		// DesktopIndex is the position of the Desktop the slot is on, it is ignored when OnAllDesktops is set
+		DesktopIndex int `json:"desktopIndex"`
+		// OnAllDesktops puts the window on all desktops
+		OnAllDesktops bool `json:"onAllDesktops"`
+	}
+	// LayoutTemplate is a struct that describes an arrangement of windows, independent of the particular windows (their
+	// ids) and desktops (their ids) it was exported from, so it can be applied to whatever programs are running later
+	LayoutTemplate struct {
+		Slots []LayoutSlot `json:"slots"`
+	}
+)
+
+// screenAt returns the Screen of the Environment which contains the center of the given Rect
+func screenAt(env Environment, r Rect) (Screen, bool) {
+	x := (r.TopLeft.X + r.BottomRight.X) / 2
+	y := (r.TopLeft.Y + r.BottomRight.Y) / 2
+	center := Rect{TopLeft: Point{X: x, Y: y}, BottomRight: Point{X: x + 1, Y: y + 1}}
+	for _, s := range env.Screens {
+		if intersects(center, s.Geometry) {
+			return s, true
+		}
+	}
+	return Screen{}, false
+}
+
+// ExportTemplate returns the arrangement of the windows of the given Environment as a LayoutTemplate, with one slot for
+// every Window, from the bottom to the top of the stacking order. A slot selects the windows by the resource class of
+// the Window it was exported from and keeps its LayoutGeometry, so a minimized or maximized window keeps the place it
+// is restored to. Windows which are not on any Screen (see FindOffscreenWindows) are left out
+func ExportTemplate(env Environment) LayoutTemplate {
+	t := LayoutTemplate{Slots: make([]LayoutSlot, 0, len(env.Windows))}
+	for _, w := range env.WindowsSortedBy(WindowsByStackingOrder) {
+		if w.SpecialWindow {
+			continue
+		}
+		g := w.LayoutGeometry()
+		s, ok := screenAt(env, g)
+		if !ok {
+			continue
+		}
+		slot := LayoutSlot{
+			Filter:        WindowFilter{ResourceClass: w.ResourceClass},
+			ScreenName:    s.Name,
+			OnAllDesktops: w.OnAllDesktops,
+			Geometry: Rect{
+				TopLeft:     Point{X: g.TopLeft.X - s.Geometry.TopLeft.X, Y: g.TopLeft.Y - s.Geometry.TopLeft.Y},
+				BottomRight: Point{X: g.BottomRight.X - s.Geometry.TopLeft.X, Y: g.BottomRight.Y - s.Geometry.TopLeft.Y},
+			},
+		}
+		if slot.Filter.ResourceClass == "" {
+			slot.Filter.ResourceName = w.ResourceName
+		}
+		if !w.OnAllDesktops && len(w.DesktopIds) > 0 {
+			slot.DesktopIndex = env.Desktops[w.DesktopIds[0]].Index
+		}
+		t.Slots = append(t.Slots, slot)
+	}
+	return t
+}
+
+// matchSlots assigns the windows of the given Environment to the slots of the template, in the order of the slots:
+// every slot gets the first Window, in the order of the window ids, which its Filter selects and which no earlier slot
+// took. The result maps the slot index to the Window, slots without a matching Window are left out
+func (t LayoutTemplate) matchSlots(env Environment) map[int]Window {
+	windows := make([]Window, 0, len(env.Windows))
+	for _, w := range env.Windows {
+		windows = append(windows, w)
+	}
+	sortWindows(windows)
+	taken := make(map[string]bool, len(windows))
+	matched := make(map[int]Window, len(t.Slots))
+	for i, slot := range t.Slots {
+		for _, w := range windows {
+			if !taken[w.Id] && slot.Filter.Matches(w) {
+				taken[w.Id] = true
+				matched[i] = w
+				break
+			}
+		}
+	}
+	return matched
+}
+
+// ApplyTemplate will attempt to arrange the currently open windows according to the given LayoutTemplate. The windows
+// are assigned to the slots by their filters, in the order of the slots, and each matched Window is moved to the
+// desktop and the geometry of its slot. Slots on a Screen which is not connected use the first Screen, slots on a
+// desktop position which doesn't exist use the last Desktop. A failure to arrange one Window doesn't stop the others,
+// all failures are returned together as the error
+func (k KWin) ApplyTemplate(t LayoutTemplate) error {
+	env, err := k.GetEnvironment()
+	if err != nil {
+		fmt.Printf("Error getting environment: %v\n", err)
+		return err
+	}
+	if len(env.Screens) == 0 || len(env.Desktops) == 0 {
+		return fmt.Errorf("environment has no screens or desktops")
+	}
+	screens := make([]Screen, 0, len(env.Screens))
+	for _, s := range env.Screens {
+		screens = append(screens, s)
+	}
+	sort.Slice(screens, func(i, j int) bool {
+		return screens[i].Index < screens[j].Index
+	})
+	desktops := make([]Desktop, 0, len(env.Desktops))
+	for _, d := range env.Desktops {
+		desktops = append(desktops, d)
+	}
+	sortDesktops(desktops)
+
+	matched := t.matchSlots(env)
+	slots := make([]int, 0, len(matched))
+	for i := range matched {
+		slots = append(slots, i)
+	}
+	sort.Ints(slots)
+	var errs []error
+	for _, i := range slots {
+		if err := k.applySlot(t.Slots[i], matched[i], env, screens, desktops); err != nil {
+			fmt.Printf("Error applying slot %d to window %s: %v\n", i, matched[i].Id, err)
+			errs = append(errs, fmt.Errorf("slot %d, window %s: %w", i, matched[i].Id, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// applySlot moves the given Window to the desktop and the geometry of the given slot
+func (k KWin) applySlot(slot LayoutSlot, w Window, env Environment, screens []Screen, desktops []Desktop) error {
+	if slot.OnAllDesktops {
+		if !w.OnAllDesktops {
+			if err := k.PinWindowToAllDesktops(w); err != nil {
+				return err
+			}
+		}
+	} else {
+		d := desktops[min(max(slot.DesktopIndex, 0), len(desktops)-1)]
+		if err := k.EnsureWindowOnDesktops(w, []Desktop{d}); err != nil {
+			return err
+		}
+	}
+	s, ok := env.Screens[slot.ScreenName]
+	if !ok {
+		s = screens[0]
+	}
+	g := Rect{
+		TopLeft: Point{
+			X: s.Geometry.TopLeft.X + slot.Geometry.TopLeft.X,
+			Y: s.Geometry.TopLeft.Y + slot.Geometry.TopLeft.Y,
+		},
+		BottomRight: Point{
+			X: s.Geometry.TopLeft.X + slot.Geometry.BottomRight.X,
+			Y: s.Geometry.TopLeft.Y + slot.Geometry.BottomRight.Y,
+		},
+	}
+	if w.hasGeometry(g) {
+		return nil
+	}
+	return k.SetWindowGeometry(w, g)
+}
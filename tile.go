@@ -0,0 +1,102 @@
+package go_kwin6
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// bspRects splits the given area binary space partition style into n rectangles. The first rectangle takes the
+// splitRatio part of the area along its longer side, and the rest of the area is split recursively in the same way, so
+// the first rectangles are the largest ones
+func bspRects(n int, area Rect, splitRatio float64) []Rect {
+	rects := make([]Rect, 0, n)
+	for i := 0; i < n; i++ {
+		if i == n-1 {
+			rects = append(rects, area)
+			break
+		}
+		width := area.BottomRight.X - area.TopLeft.X
+		height := area.BottomRight.Y - area.TopLeft.Y
+		first, rest := area, area
+		if width >= height {
+			split := area.TopLeft.X + int(float64(width)*splitRatio)
+			first.BottomRight.X = split
+			rest.TopLeft.X = split
+		} else {
+			split := area.TopLeft.Y + int(float64(height)*splitRatio)
+			first.BottomRight.Y = split
+			rest.TopLeft.Y = split
+		}
+		rects = append(rects, first)
+		area = rest
+	}
+	return rects
+}
+
+// minTileSize is the smallest width and height, in logical pixels, TileWindowsBSP gives a window
+const minTileSize = 50
+
+// TileWindowsBSP will attempt to tile the given windows in the given area (in logical pixels), by splitting the area
+// binary space partition style: the first Window gets the splitRatio part of the area along its longer side, and the
+// rest of the area is split recursively between the remaining windows. A splitRatio of 0.5 splits the area in halves.
+// The area must fit all windows with at least minTileSize pixels each, which is checked before any window is touched,
+// and all windows are then tiled in a single script run. Windows which are not moveable or were not found are left
+// alone and reported in the error, the others are tiled nonetheless
+func (k KWin) TileWindowsBSP(windows []Window, area Rect, splitRatio float64) error {
+	action := `(function() {
+        var geometries = %s;
+        return function(window) {
+            var g = geometries[window.internalId.toString().replace(/{/, "").replace(/}/, "")];
+            if (!g || !window.moveable) {
+                return false;
+            }
+            var current = window.frameGeometry;
+            var width = window.resizeable ? g.width : current.width;
+            var height = window.resizeable ? g.height : current.height;
+            window.frameGeometry = {x: g.x, y: g.y, width: width, height: height};
+            return true;
+        };
+    })()`
+	if splitRatio <= 0 || splitRatio >= 1 {
+		return fmt.Errorf("invalid split ratio: %v", splitRatio)
+	}
+	if area.BottomRight.X <= area.TopLeft.X || area.BottomRight.Y <= area.TopLeft.Y {
+		return fmt.Errorf("invalid tiling area: %v", area)
+	}
+	if len(windows) == 0 {
+		return nil
+	}
+	type geometry struct {
+		X      int `json:"x"`
+		Y      int `json:"y"`
+		Width  int `json:"width"`
+		Height int `json:"height"`
+	}
+	geometries := make(map[string]geometry, len(windows))
+	for i, r := range bspRects(len(windows), area, splitRatio) {
+		width := r.BottomRight.X - r.TopLeft.X
+		height := r.BottomRight.Y - r.TopLeft.Y
+		if width < minTileSize || height < minTileSize {
+			return fmt.Errorf("tiling area %v is too small for %d windows", area, len(windows))
+		}
+		geometries[windows[i].Id] = geometry{X: r.TopLeft.X, Y: r.TopLeft.Y, Width: width, Height: height}
+	}
+	js, err := json.Marshal(geometries)
+	if err != nil {
+		return err
+	}
+	failed, err := k.runWindowBatch(windows, fmt.Sprintf(action, js))
+	if err != nil {
+		fmt.Printf("Error tiling windows: %v\n", err)
+		return err
+	}
+	if len(failed) > 0 {
+		ids := make([]string, len(failed))
+		for i, w := range failed {
+			ids[i] = w.Id
+		}
+		return fmt.Errorf("windows not tiled: %s", strings.Join(ids, ", "))
+	}
+	return nil
+}
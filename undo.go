@@ -0,0 +1,89 @@
+package go_kwin6
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+)
+
+// ApplyWithUndo snapshots all windows, runs fn, which is expected to change the layout through this KWin object, and
+// returns a function which restores the snapshot. The undo function restores the desktops, the minimized and maximized
+// state and the geometry of every snapshotted Window which still exists and changed, so it also reverts changes made
+// by anything else in the meantime. Windows opened after the snapshot are left alone. The undo function is returned
+// even when fn fails, so a partially applied change can be reverted too
+func (k KWin) ApplyWithUndo(fn func() error) (func() error, error) {
+	desktops, err := k.GetDesktops()
+	if err != nil {
+		fmt.Printf("Error getting desktops: %v\n", err)
+		return nil, err
+	}
+	snapshot, err := k.GetWindows(desktops)
+	if err != nil {
+		fmt.Printf("Error getting windows: %v\n", err)
+		return nil, err
+	}
+	undo := func() error {
+		return k.restoreWindows(snapshot)
+	}
+	return undo, fn()
+}
+
+// restoreWindows restores the state of the given windows, as described in ApplyWithUndo. A failure to restore one
+// Window doesn't stop the others, all failures are returned together as the error
+func (k KWin) restoreWindows(snapshot map[uuid.UUID]Window) error {
+	desktops, err := k.GetDesktops()
+	if err != nil {
+		fmt.Printf("Error getting desktops: %v\n", err)
+		return err
+	}
+	current, err := k.GetWindows(desktops)
+	if err != nil {
+		fmt.Printf("Error getting windows: %v\n", err)
+		return err
+	}
+	var errs []error
+	for id, prev := range snapshot {
+		w, ok := current[id]
+		if !ok {
+			continue
+		}
+		if err := k.restoreWindow(prev, w); err != nil {
+			fmt.Printf("Error restoring window %s: %v\n", id, err)
+			errs = append(errs, fmt.Errorf("window %s: %w", id, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// restoreWindow brings the current state w of a Window back to its previous state prev
+func (k KWin) restoreWindow(prev, w Window) error {
+	switch {
+	case prev.OnAllDesktops && !w.OnAllDesktops:
+		if err := k.PinWindowToAllDesktops(w); err != nil {
+			return err
+		}
+	case !prev.OnAllDesktops && len(prev.Desktops) > 0 && (w.OnAllDesktops || !sameElements(prev.DesktopIds, w.DesktopIds)):
+		if err := k.MoveWindowToDesktops(w, prev.Desktops); err != nil {
+			return err
+		}
+	}
+	if prev.MaximizedHorizontally != w.MaximizedHorizontally || prev.MaximizedVertically != w.MaximizedVertically {
+		err := k.maximizeWindowHV(context.Background(), w, prev.MaximizedHorizontally, prev.MaximizedVertically)
+		if err != nil {
+			return err
+		}
+	}
+	if !prev.Minimized && !prev.MaximizedHorizontally && !prev.MaximizedVertically && prev.Geometry() != w.Geometry() {
+		if err := k.SetWindowGeometry(w, prev.Geometry()); err != nil {
+			return err
+		}
+	}
+	if prev.Minimized != w.Minimized {
+		if _, err := k.ToggleWindowMinimized(w); err != nil {
+			return err
+		}
+	}
+	return nil
+}
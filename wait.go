@@ -0,0 +1,36 @@
+package go_kwin6
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// defaultWaitPoll is the polling interval WaitForWindowState uses when none is given
+const defaultWaitPoll = 100 * time.Millisecond
+
+// WaitForWindowState polls a given Window with RefreshWindow every poll interval until the predicate holds for its
+// current state, and returns that state. The mutators don't wait for KWin to apply a change, so e.g. after
+// SetWindowFullscreen the window may not be fullscreen yet. A non-positive poll uses a default of 100ms. It returns an
+// error when ctx is done first, or when the window can't be refreshed, e.g. because it was closed
+func (k KWin) WaitForWindowState(ctx context.Context, w Window, predicate func(Window) bool, poll time.Duration) (Window, error) {
+	if poll <= 0 {
+		poll = defaultWaitPoll
+	}
+	ticker := time.NewTicker(poll)
+	defer ticker.Stop()
+	for {
+		current, err := k.RefreshWindow(w)
+		if err != nil {
+			return Window{}, err
+		}
+		if predicate(current) {
+			return current, nil
+		}
+		select {
+		case <-ctx.Done():
+			return current, fmt.Errorf("waiting for window %s state: %w", w.Id, ctx.Err())
+		case <-ticker.C:
+		}
+	}
+}
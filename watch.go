@@ -0,0 +1,451 @@
+package go_kwin6
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// ScreenAdded is reported when a screen/monitor is connected
+	ScreenAdded ScreenEventType = "added"
+	// ScreenRemoved is reported when a screen/monitor is disconnected
+	ScreenRemoved ScreenEventType = "removed"
+	// ScreenChanged is reported when a connected screen changes its properties, e.g. its geometry or pixel ratio
+	ScreenChanged ScreenEventType = "changed"
+
+	// WindowAdded is reported when a new window appears
+	WindowAdded WindowEventType = "added"
+	// WindowRemoved is reported when a window is closed
+	WindowRemoved WindowEventType = "removed"
+)
+
+type (
+	// ScreenEventType describes what happened to a Screen in a ScreenEvent
+	ScreenEventType string
+	// ScreenEvent is a struct that describes a change in the connected screens, as reported by WatchScreens
+	ScreenEvent struct {
+		Type   ScreenEventType `json:"type"`
+		Screen Screen          `json:"screen"`
+	}
+	// WindowEventType describes what happened to a Window in a WindowEvent
+	WindowEventType string
+	// WindowEvent is a struct that describes a change in the client program windows, as reported by WatchWindows
+	WindowEvent struct {
+		Type   WindowEventType `json:"type"`
+		Window Window          `json:"window"`
+	}
+	// StackingOrderEvent is a struct that describes a change of the stacking order (z-order), as reported by
+	//WatchStackingOrder. WindowIds are the ids of the windows, except the special ones, from the bottom to the top
+	StackingOrderEvent struct {
+		WindowIds []uuid.UUID `json:"windowIds"`
+	}
+)
+
+// watchRestartDelay is the time to wait before restarting a watch event source which exited prematurely
+const watchRestartDelay = time.Second
+
+// watchScript loads and runs a long-running JavaScript scriptlet, which is expected to connect to KWin signals and print
+// its events prefixed by the given tag. For the lifetime of the script an event source (journalctl --follow, or
+// dbus-monitor when OutputMode is OutputDBus) writes into a named pipe (FIFO) next to the script file, which is read line
+// by line, and every tagged line is sent (with the tag removed) to the returned channel. Should the event source exit
+// prematurely, it is restarted and the reading of the FIFO continues. When ctx is cancelled the script is stopped and
+// deregistered, the event source is killed, the script file and the FIFO are deleted and the channel is closed
+func (k KWin) watchScript(ctx context.Context, script, tag string) (<-chan string, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	release, err := k.trackWatch(cancel)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	started := false
+	defer func() {
+		if !started {
+			cancel()
+			release()
+		}
+	}()
+
+	path := "/GoKWin6/Watch_" + strings.ReplaceAll(uuid.NewString(), "-", "_")
+	if k.OutputMode == OutputDBus {
+		script = fmt.Sprintf(dbusPrintShim, path) + script
+	}
+	scriptFile, err := k.createScriptFile(script)
+	if err != nil {
+		return nil, err
+	}
+
+	fifoName := scriptFile.Name() + ".fifo"
+	if err := syscall.Mkfifo(fifoName, 0600); err != nil {
+		k.removeScriptFile(scriptFile)
+		return nil, err
+	}
+	// The FIFO is opened for both reading and writing, so the open doesn't block waiting for a writer and the reading
+	// doesn't end with EOF when the event source goes away, which allows restarting it without reopening the FIFO
+	fifo, err := os.OpenFile(fifoName, os.O_RDWR, 0)
+	if err != nil {
+		_ = os.Remove(fifoName)
+		k.removeScriptFile(scriptFile)
+		return nil, err
+	}
+	cleanup := func() {
+		_ = fifo.Close()
+		if err := os.Remove(fifoName); err != nil {
+			fmt.Printf("Error removing watch fifo: %v\n", err)
+		}
+		k.removeScriptFile(scriptFile)
+	}
+
+	startSource := func() (*exec.Cmd, error) {
+		var cmd *exec.Cmd
+		if k.OutputMode == OutputDBus {
+			cmd = exec.Command(dbusMonitor, "--session", fmt.Sprintf("type='method_call',path='%s'", path))
+		} else {
+			cmd = exec.Command(journalCtl, k.journalArgs(
+				"--since", k.now().Format(journalTimeFormat),
+				"--follow",
+				"--no-pager")...)
+		}
+		w, err := os.OpenFile(fifoName, os.O_WRONLY, 0)
+		if err != nil {
+			return nil, err
+		}
+		defer w.Close()
+		cmd.Stdout = w
+		return cmd, cmd.Start()
+	}
+	cmd, err := startSource()
+	if err != nil {
+		cleanup()
+		return nil, err
+	}
+
+	scriptNo, err := k.loadScript(ctx, scriptFile.Name())
+	if err != nil {
+		fmt.Printf("Error loading watch script: %v\n", err)
+		_ = cmd.Process.Kill()
+		_ = cmd.Wait()
+		cleanup()
+		return nil, err
+	}
+	err = k.runScript(ctx, scriptNo)
+	if err != nil {
+		fmt.Printf("Error running watch script: %v\n", err)
+		_ = k.stopScript(context.Background(), scriptNo)
+		_ = cmd.Process.Kill()
+		_ = cmd.Wait()
+		cleanup()
+		return nil, err
+	}
+
+	started = true
+	go func() {
+		defer func() {
+			if err := k.stopScript(context.Background(), scriptNo); err != nil {
+				fmt.Printf("Error stopping watch script: %v\n", err)
+			}
+			cleanup()
+			cancel()
+			release()
+		}()
+		for {
+			exited := make(chan struct{})
+			go func(cmd *exec.Cmd) {
+				_ = cmd.Wait()
+				close(exited)
+			}(cmd)
+			select {
+			case <-ctx.Done():
+				_ = cmd.Process.Kill()
+				<-exited
+				return
+			case <-exited:
+				fmt.Printf("Watch event source exited, restarting\n")
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(watchRestartDelay):
+			}
+			cmd, err = startSource()
+			if err != nil {
+				fmt.Printf("Error restarting watch event source: %v\n", err)
+				return
+			}
+		}
+	}()
+
+	lines := make(chan string)
+	go func() {
+		defer close(lines)
+		scanner := bufio.NewScanner(fifo)
+		for scanner.Scan() {
+			s := scriptLine(scanner.Text())
+			if k.OutputMode == OutputDBus {
+				var ok bool
+				if s, ok = dbusPrintedLine(scanner.Text()); !ok {
+					continue
+				}
+			}
+			if !strings.HasPrefix(s, tag) {
+				continue
+			}
+			select {
+			case lines <- strings.TrimPrefix(s, tag):
+			case <-ctx.Done():
+			}
+		}
+	}()
+	return lines, nil
+}
+
+// newWatchTag returns a unique prefix with which a watch script marks its output lines, so that they can be told apart
+// from the output of any other script running at the same time
+func newWatchTag() string {
+	return "#" + uuid.NewString() + "#"
+}
+
+// WatchDesktopChanges returns a channel which receives the new current Desktop each time the user switches the virtual
+// desktop. The backing script keeps running until ctx is cancelled, after which the channel is closed
+func (k KWin) WatchDesktopChanges(ctx context.Context) (<-chan Desktop, error) {
+	script := desktopToJSON + `
+	var tag = "%s";
+	workspace.currentDesktopChanged.connect(function() {
+		var desktop = workspace.currentDesktop
+		for (var i = 0; i < workspace.desktops.length; i++) {
+			if (workspace.desktops[i].id === desktop.id) {
+				print(tag+desktopToJSON(i))
+				break;
+			}
+		}
+	});`
+	tag := newWatchTag()
+	lines, err := k.watchScript(ctx, fmt.Sprintf(script, tag), tag)
+	if err != nil {
+		fmt.Printf("Error running script for desktop changes: %v\n", err)
+		return nil, err
+	}
+	desktops := make(chan Desktop)
+	go func() {
+		defer close(desktops)
+		for s := range lines {
+			d, err := parseDesktopLine(s)
+			if err != nil {
+				fmt.Printf("Error parsing desktop change: %v\n", err)
+				continue
+			}
+			select {
+			case desktops <- d:
+			case <-ctx.Done():
+			}
+		}
+	}()
+	return desktops, nil
+}
+
+// WatchScreens returns a channel which receives a ScreenEvent every time a screen/monitor is connected, disconnected or
+// its properties (e.g. geometry) change. The current screens are read first and every subsequent KWin screensChanged
+// signal is compared against the last known state. The backing script keeps running until ctx is cancelled, after
+// which the channel is closed
+func (k KWin) WatchScreens(ctx context.Context) (<-chan ScreenEvent, error) {
+	script := screenToJSON + `
+	var tag = "%s";
+	workspace.screensChanged.connect(function() {
+		print(tag+"#"+workspace.screens.length)
+		for (var i = 0; i < workspace.screens.length; i++) {
+			print(tag+screenToJSON(i))
+		}
+	});`
+	known, err := k.GetScreens()
+	if err != nil {
+		fmt.Printf("Error getting screens: %v\n", err)
+		return nil, err
+	}
+	tag := newWatchTag()
+	lines, err := k.watchScript(ctx, fmt.Sprintf(script, tag), tag)
+	if err != nil {
+		fmt.Printf("Error running script for screen changes: %v\n", err)
+		return nil, err
+	}
+	events := make(chan ScreenEvent)
+	go func() {
+		defer close(events)
+		// every change is reported as a "#<count>" line followed by a line for each of the screens
+		expected := -1
+		current := make(map[string]Screen)
+		for s := range lines {
+			if strings.HasPrefix(s, "#") {
+				count, err := strconv.Atoi(strings.TrimPrefix(s, "#"))
+				if err != nil {
+					fmt.Printf("Error parsing screen change: %v\n", err)
+					expected = -1
+					continue
+				}
+				expected = count
+				current = make(map[string]Screen, count)
+			} else if expected >= 0 {
+				screen, err := parseScreenLine(s)
+				if err != nil {
+					fmt.Printf("Error parsing screen change: %v\n", err)
+					expected = -1
+					continue
+				}
+				current[screen.Name] = screen
+			}
+			if expected < 0 || len(current) < expected {
+				continue
+			}
+			expected = -1
+			for _, e := range screenEvents(known, current) {
+				select {
+				case events <- e:
+				case <-ctx.Done():
+					return
+				}
+			}
+			known = current
+		}
+	}()
+	return events, nil
+}
+
+// screenEvents compares two sets of screens, keyed by name, and returns the events which turn the old set into the new
+func screenEvents(old, new map[string]Screen) []ScreenEvent {
+	events := make([]ScreenEvent, 0)
+	for name, s := range old {
+		if _, ok := new[name]; !ok {
+			events = append(events, ScreenEvent{Type: ScreenRemoved, Screen: s})
+		}
+	}
+	for name, s := range new {
+		prev, ok := old[name]
+		if !ok {
+			events = append(events, ScreenEvent{Type: ScreenAdded, Screen: s})
+		} else if !prev.Equal(s) {
+			events = append(events, ScreenEvent{Type: ScreenChanged, Screen: s})
+		}
+	}
+	return events
+}
+
+// WatchWindows returns a channel which receives a WindowEvent every time a client program window is added or removed.
+// Added windows are enriched with the process command line and application name like in GetWindows, removed windows
+// only carry what KWin reported, as their process may be gone already. The backing script keeps running until ctx is
+// cancelled, after which the channel is closed
+func (k KWin) WatchWindows(ctx context.Context) (<-chan WindowEvent, error) {
+	script := windowToJSON + `
+	var tag = "%s";
+	workspace.windowAdded.connect(function(window) {
+		if (!window.specialWindow) {
+			print(tag+"{\"type\": \"added\", \"window\": "+windowToJSON(window)+"}")
+		}
+	});
+	workspace.windowRemoved.connect(function(window) {
+		if (!window.specialWindow) {
+			print(tag+"{\"type\": \"removed\", \"window\": "+windowToJSON(window)+"}")
+		}
+	});`
+	tag := newWatchTag()
+	lines, err := k.watchScript(ctx, fmt.Sprintf(script, tag), tag)
+	if err != nil {
+		fmt.Printf("Error running script for window changes: %v\n", err)
+		return nil, err
+	}
+	events := make(chan WindowEvent)
+	go func() {
+		defer close(events)
+		for s := range lines {
+			raw := struct {
+				Type   WindowEventType `json:"type"`
+				Window json.RawMessage `json:"window"`
+			}{}
+			if err := json.Unmarshal([]byte(s), &raw); err != nil {
+				fmt.Printf("Error parsing window change: %v\n", err)
+				continue
+			}
+			e := WindowEvent{Type: raw.Type}
+			if raw.Type == WindowAdded {
+				e.Window, err = k.parseWindow(string(raw.Window), nil)
+			} else {
+				err = json.Unmarshal(raw.Window, &e.Window)
+			}
+			if err != nil {
+				fmt.Printf("Error parsing window change: %v\n", err)
+				continue
+			}
+			select {
+			case events <- e:
+			case <-ctx.Done():
+			}
+		}
+	}()
+	return events, nil
+}
+
+// WatchStackingOrder returns a channel which receives a StackingOrderEvent every time the stacking order (z-order) of
+// the windows changes, e.g. when a window is raised, lowered or activated, with the new order of all windows. A change
+// which moves several windows at once is reported once. The backing script keeps running until ctx is cancelled, after
+// which the channel is closed
+func (k KWin) WatchStackingOrder(ctx context.Context) (<-chan StackingOrderEvent, error) {
+	script := `
+	var tag = "%s";
+	var last = "";
+	function reportStackingOrder() {
+		var ids = [];
+		for (const window of workspace.stackingOrder) {
+			if (!window.specialWindow) {
+				ids.push(window.internalId.toString().replace(/{/, "").replace(/}/, ""));
+			}
+		}
+		var out = JSON.stringify({windowIds: ids});
+		if (out !== last) {
+			last = out;
+			print(tag+out);
+		}
+	}
+	function connectWindow(window) {
+		if (window.stackingOrderChanged) {
+			window.stackingOrderChanged.connect(reportStackingOrder);
+		}
+	}
+	for (const window of workspace.windowList()) {
+		connectWindow(window);
+	}
+	workspace.windowAdded.connect(function(window) {
+		connectWindow(window);
+		reportStackingOrder();
+	});
+	workspace.windowRemoved.connect(reportStackingOrder);
+	workspace.windowActivated.connect(reportStackingOrder);`
+	tag := newWatchTag()
+	lines, err := k.watchScript(ctx, fmt.Sprintf(script, tag), tag)
+	if err != nil {
+		fmt.Printf("Error running script for stacking order changes: %v\n", err)
+		return nil, err
+	}
+	events := make(chan StackingOrderEvent)
+	go func() {
+		defer close(events)
+		for s := range lines {
+			e := StackingOrderEvent{}
+			if err := json.Unmarshal([]byte(s), &e); err != nil {
+				fmt.Printf("Error parsing stacking order change: %v\n", err)
+				continue
+			}
+			select {
+			case events <- e:
+			case <-ctx.Done():
+			}
+		}
+	}()
+	return events, nil
+}